@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the file given with -config.file.
+type Config struct {
+	Targets []Target `yaml:"targets"`
+}
+
+// Target describes a JVM to monitor and how to run jstat against it.
+type Target struct {
+	// Name is the main class or jar of the JVM, resolved with jps. It is
+	// also the value of the target label when read from a config file.
+	Name      string `yaml:"name"`
+	MatchArgs bool   `yaml:"match_args"`
+	// AllMatches monitors every JVM matching Name rather than the first.
+	AllMatches bool `yaml:"all_matches"`
+	// User only keeps the JVMs run by this user name or uid.
+	User      string `yaml:"user"`
+	JstatPath string `yaml:"jstat_path"`
+	// Remote is the host:port of the jstatd the JVM is reached through.
+	Remote string `yaml:"remote"`
+	// Modes are the jstat options to collect, e.g. "gc" or "gcutil". All
+	// modes are collected when empty.
+	Modes []string `yaml:"modes"`
+	// Interval is the minimum time between runs of each jstat mode, for
+	// the modes without an -interval.<mode> of their own.
+	Interval time.Duration `yaml:"interval"`
+	// Pids are used instead of Name when Name is empty. They can only be
+	// set with -target.pid.
+	Pids []string `yaml:"-"`
+	// PidFile is read for the pid instead of using Pids when Name is
+	// empty. It can only be set with -target.pidfile.
+	PidFile string `yaml:"-"`
+	// Timestamp runs jstat with -t. It is set with -jstat.timestamp.
+	Timestamp bool `yaml:"-"`
+}
+
+// LoadConfig reads and validates a config file.
+func LoadConfig(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(content, config); err != nil {
+		return nil, fmt.Errorf("parsing %s: %s", path, err)
+	}
+	if len(config.Targets) == 0 {
+		return nil, fmt.Errorf("%s: no targets", path)
+	}
+	names := make(map[string]bool)
+	for _, t := range config.Targets {
+		if t.Name == "" {
+			return nil, fmt.Errorf("%s: target without a name", path)
+		}
+		if names[t.Name] {
+			return nil, fmt.Errorf("%s: duplicate target %s", path, t.Name)
+		}
+		names[t.Name] = true
+		if t.Interval < 0 {
+			return nil, fmt.Errorf("%s: target %s: negative interval %s", path, t.Name, t.Interval)
+		}
+		for _, mode := range t.Modes {
+			if !knownMode(mode) {
+				return nil, fmt.Errorf("%s: target %s: unknown mode %s", path, t.Name, mode)
+			}
+		}
+	}
+	return config, nil
+}
+
+// knownMode reports whether mode is one of jstatModes.
+func knownMode(mode string) bool {
+	for _, m := range jstatModes {
+		if m.name == mode {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// generationLine matches the generation lines of most collectors, e.g.
+	// " garbage-first heap   total 262144K, used 19262K [...)" or
+	// " PSYoungGen      total 76288K, used 3932K [...)".
+	generationLine = regexp.MustCompile(`^\s*(\S[^,]*?)\s+total (\d+)([KMG]), used (\d+)([KMG])`)
+	// zHeapLine matches ZGC's " ZHeap           used 8M, capacity 500M, ...".
+	zHeapLine = regexp.MustCompile(`^\s*ZHeap\s+used (\d+)([KMG]), capacity (\d+)([KMG])`)
+	// regionLine matches G1's "  region size 1024K, 14 young (14336K),
+	// 2 survivors (2048K)", whose young regions include the survivors.
+	regionLine = regexp.MustCompile(`^\s*region size \d+[KMG], \d+ young \((\d+)([KMG])\), \d+ survivors \((\d+)([KMG])\)`)
+	// spaceLine matches the eden and survivor spaces of Parallel, Serial and
+	// CMS, e.g. "  eden space 65536K, 6% used [0x...6ab00000,0x...6aed7240,
+	// 0x...6eb00000)". The used part runs from the first address to the
+	// second.
+	spaceLine = regexp.MustCompile(`^\s*(eden|from|to)\s+space (\d+)([KMG]),\s+\d+% used \[0x([0-9a-f]+),\s*0x([0-9a-f]+),`)
+	// metaspaceLine matches " Metaspace       used 6123K, committed 6336K,
+	// ..." and "  class space    used 539K, committed 640K, ...". JDK 15
+	// and earlier print a capacity before the committed size.
+	metaspaceLine = regexp.MustCompile(`^\s*(Metaspace|class space)\s+used (\d+)([KMG]),(?: capacity \d+[KMG],)? committed (\d+)([KMG])`)
+)
+
+// youngGenerations and oldGenerations are the generation names of Parallel,
+// Serial and CMS. G1 and ZGC print the heap as a whole.
+var (
+	youngGenerations = map[string]bool{"PSYoungGen": true, "def new generation": true, "par new generation": true}
+	oldGenerations   = map[string]bool{"ParOldGen": true, "PSOldGen": true, "tenured generation": true, "concurrent mark-sweep generation": true}
+)
+
+// heapSpace is the used and committed size of a space in kB. Either is -1
+// when GC.heap_info does not print it.
+type heapSpace struct {
+	used      float64
+	committed float64
+}
+
+// add adds the sizes of a space that is part of s.
+func (s *heapSpace) add(used float64, committed float64) {
+	s.used = math.Max(s.used, 0) + used
+	s.committed = math.Max(s.committed, 0) + committed
+}
+
+// heapInfo holds the sizes jcmd GC.heap_info reports. survivors are both
+// survivor spaces together, since GC.heap_info names them from and to, which
+// swap after every young GC rather than staying S0 and S1.
+type heapInfo struct {
+	heap      heapSpace
+	young     heapSpace
+	eden      heapSpace
+	survivors heapSpace
+	old       heapSpace
+	meta      heapSpace
+	ccs       heapSpace
+}
+
+// parseHeapInfo reads the output of jcmd <pid> GC.heap_info. The heap is the
+// sum of the generations a collector lists, so it covers G1, Parallel, Serial
+// and CMS as well as ZGC. What a collector does not print is left at -1: the
+// eden, survivor and old capacities under G1, and everything but the heap
+// under ZGC.
+func parseHeapInfo(out string) (heapInfo, error) {
+	unknown := heapSpace{-1, -1}
+	info := heapInfo{unknown, unknown, unknown, unknown, unknown, unknown, unknown}
+	g1 := false
+	for _, line := range strings.Split(out, "\n") {
+		if m := generationLine.FindStringSubmatch(line); m != nil {
+			committed, used := kilobytes(m[2], m[3]), kilobytes(m[4], m[5])
+			info.heap.add(used, committed)
+			switch {
+			case youngGenerations[m[1]]:
+				info.young = heapSpace{used, committed}
+			case oldGenerations[m[1]]:
+				info.old = heapSpace{used, committed}
+			}
+		} else if m := zHeapLine.FindStringSubmatch(line); m != nil {
+			info.heap.add(kilobytes(m[1], m[2]), kilobytes(m[3], m[4]))
+		} else if m := regionLine.FindStringSubmatch(line); m != nil {
+			info.young.used = kilobytes(m[1], m[2])
+			info.survivors.used = kilobytes(m[3], m[4])
+			g1 = true
+		} else if m := spaceLine.FindStringSubmatch(line); m != nil {
+			bottom, _ := strconv.ParseUint(m[4], 16, 64)
+			top, _ := strconv.ParseUint(m[5], 16, 64)
+			used, committed := float64(top-bottom)/1024, kilobytes(m[2], m[3])
+			if m[1] == "eden" {
+				info.eden = heapSpace{used, committed}
+			} else {
+				info.survivors.add(used, committed)
+			}
+		} else if m := metaspaceLine.FindStringSubmatch(line); m != nil {
+			space := heapSpace{kilobytes(m[2], m[3]), kilobytes(m[4], m[5])}
+			if m[1] == "Metaspace" {
+				info.meta = space
+			} else {
+				info.ccs = space
+			}
+		}
+	}
+	if info.heap.used < 0 {
+		return info, fmt.Errorf("no heap sizes in output %q", out)
+	}
+	if g1 {
+		info.eden.used = info.young.used - info.survivors.used
+		info.old.used = info.heap.used - info.young.used
+	}
+	// A young generation's total leaves out the empty survivor space,
+	// which jstat counts.
+	if info.young.committed >= 0 && info.eden.committed >= 0 && info.survivors.committed >= 0 {
+		young := info.eden.committed + info.survivors.committed
+		info.heap.committed += young - info.young.committed
+		info.young.committed = young
+	}
+	return info, nil
+}
+
+// kilobytes converts a size printed by jcmd, such as "512" with unit "M", to kB.
+func kilobytes(size string, unit string) float64 {
+	value, _ := strconv.ParseFloat(size, 64)
+	switch unit {
+	case "M":
+		value *= 1024
+	case "G":
+		value *= 1024 * 1024
+	}
+	return value
+}
+
+// heapInfoModes are the jstat modes whose metrics jcmd GC.heap_info can fill
+// in.
+var heapInfoModes = []string{"gc", "gcnew", "gcold", "gccapacity"}
+
+// collectHeapInfo fills in the heap and metaspace metrics from jcmd <pid>
+// GC.heap_info for the enabled heapInfoModes that did not return data for
+// pid, which happens when jstat cannot read the JVM's performance data. It
+// reports whether it collected any metric; jcmd is not run when no such mode
+// is missing.
+func (e *Exporter) collectHeapInfo(ch chan<- prometheus.Metric, pid string, collected map[string]bool) (bool, error) {
+	missing := false
+	for _, mode := range heapInfoModes {
+		if e.enabled(mode) && !collected[mode] {
+			missing = true
+		}
+	}
+	if !missing {
+		return false, nil
+	}
+	var stderr bytes.Buffer
+	ctx, cancel := context.WithTimeout(e.ctx, e.jstatTimeout)
+	defer cancel()
+	cmd := toolCommand(ctx, e.jcmdPath, pid, "GC.heap_info")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		slog.Warn("jcmd GC.heap_info wrote to stderr", "pid", pid, "stderr", msg)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", e.jstatTimeout)
+	}
+	if err == nil {
+		var info heapInfo
+		info, err = parseHeapInfo(string(out))
+		if err == nil {
+			return e.setHeapInfo(ch, pid, info, collected), nil
+		}
+	}
+	e.commandErrors.WithLabelValues("heap_info").Inc()
+	return false, fmt.Errorf("jcmd %s GC.heap_info: %s", pid, err)
+}
+
+// setHeapInfo collects the sizes of info for the enabled heapInfoModes that
+// collected is missing and reports whether there were any. Sizes GC.heap_info
+// did not print are left out.
+func (e *Exporter) setHeapInfo(ch chan<- prometheus.Metric, pid string, info heapInfo, collected map[string]bool) bool {
+	scale := 1.0
+	if e.bytes {
+		scale = 1024
+	}
+	emitted := false
+	set := func(m *prometheus.GaugeVec, kB float64) {
+		if kB < 0 {
+			return
+		}
+		g := m.WithLabelValues(pid)
+		g.Set(kB * scale)
+		g.Collect(ch)
+		emitted = true
+	}
+	ratio := func(m *prometheus.GaugeVec, space heapSpace) {
+		if space.used < 0 || space.committed <= 0 {
+			return
+		}
+		g := m.WithLabelValues(pid)
+		g.Set(space.used / space.committed)
+		g.Collect(ch)
+		emitted = true
+	}
+	missing := func(mode string) bool {
+		return e.enabled(mode) && !collected[mode]
+	}
+	if missing("gc") {
+		set(e.heapUsed, info.heap.used)
+		set(e.heapCommitted, info.heap.committed)
+		set(e.edenCapacity, info.eden.committed)
+		set(e.oldCapacity, info.old.committed)
+		set(e.ccsCapacity, info.ccs.committed)
+		set(e.ccsUsed, info.ccs.used)
+	}
+	if missing("gcnew") {
+		set(e.edenUsed, info.eden.used)
+		ratio(e.edenUtilization, info.eden)
+		ratio(e.survivorUtilization, info.survivors)
+	}
+	if missing("gcold") {
+		set(e.oldUsed, info.old.used)
+		set(e.metaUsed, info.meta.used)
+		ratio(e.oldUtilization, info.old)
+	}
+	if missing("gccapacity") {
+		set(e.newCommit, info.young.committed)
+		set(e.oldCommit, info.old.committed)
+		set(e.oldCurrent, info.old.committed)
+		set(e.metaCommit, info.meta.committed)
+		set(e.ccsCurrent, info.ccs.committed)
+	}
+	return emitted
+}
@@ -0,0 +1,189 @@
+package main
+
+import "testing"
+
+// Output of jcmd <pid> GC.heap_info under the collectors it has a layout for.
+const (
+	jdk17G1HeapInfo = `12345:
+ garbage-first heap   total 262144K, used 19262K [0x00000000f0000000, 0x0000000100000000)
+  region size 1024K, 14 young (14336K), 2 survivors (2048K)
+ Metaspace       used 6123K, committed 6336K, reserved 1056768K
+  class space    used 539K, committed 640K, reserved 1048576K
+`
+	jdk8G1HeapInfo = `12345:
+ garbage-first heap   total 262144K, used 19262K [0x00000000f0000000, 0x00000000f0100800, 0x0000000100000000)
+  region size 1024K, 14 young (14336K), 2 survivors (2048K)
+ Metaspace       used 6123K, capacity 6262K, committed 6528K, reserved 1056768K
+  class space    used 539K, capacity 594K, committed 640K, reserved 1048576K
+`
+	jdk17ParallelHeapInfo = `12345:
+ PSYoungGen      total 76288K, used 3932K [0x000000076ab00000, 0x0000000770000000, 0x00000007c0000000)
+  eden space 65536K, 6% used [0x000000076ab00000,0x000000076aed7240,0x000000076eb00000)
+  from space 10752K, 0% used [0x000000076f580000,0x000000076f580000,0x0000000770000000)
+  to   space 10752K, 0% used [0x000000076eb00000,0x000000076eb00000,0x000000076f580000)
+ ParOldGen       total 175104K, used 1024K [0x00000006c0000000, 0x00000006cab00000, 0x000000076ab00000)
+  object space 175104K, 0% used [0x00000006c0000000,0x00000006c0100000,0x00000006cab00000)
+ Metaspace       used 6123K, committed 6336K, reserved 1056768K
+  class space    used 539K, committed 640K, reserved 1048576K
+`
+	jdk8SerialHeapInfo = `12345:
+ def new generation   total 9792K, used 2680K [0x00000000fec00000, 0x00000000ff6a0000, 0x00000000ff6a0000)
+  eden space 8704K,  30% used [0x00000000fec00000, 0x00000000fee9e200, 0x00000000ff480000)
+  from space 1088K,  50% used [0x00000000ff480000, 0x00000000ff508000, 0x00000000ff590000)
+  to   space 1088K,   0% used [0x00000000ff590000, 0x00000000ff590000, 0x00000000ff6a0000)
+ tenured generation   total 21888K, used 0K [0x00000000ff6a0000, 0x00000000ff6a0000, 0x0000000100000000)
+   the space 21888K,   0% used [0x00000000ff6a0000, 0x00000000ff6a0000, 0x00000000ff6a0200, 0x0000000100000000)
+ Metaspace       used 2698K, capacity 4486K, committed 4864K, reserved 1056768K
+  class space    used 288K, capacity 386K, committed 512K, reserved 1048576K
+`
+	jdk17ZgcHeapInfo = `12345:
+ ZHeap           used 8M, capacity 500M, max capacity 4096M
+ Metaspace       used 6123K, committed 6336K, reserved 1056768K
+  class space    used 539K, committed 640K, reserved 1048576K
+`
+)
+
+func TestParseHeapInfo(t *testing.T) {
+	unknown := heapSpace{-1, -1}
+	tests := []struct {
+		name string
+		out  string
+		want heapInfo
+	}{
+		{
+			name: "G1",
+			out:  jdk17G1HeapInfo,
+			want: heapInfo{
+				heap:      heapSpace{19262, 262144},
+				young:     heapSpace{14336, -1},
+				eden:      heapSpace{12288, -1},
+				survivors: heapSpace{2048, -1},
+				old:       heapSpace{4926, -1},
+				meta:      heapSpace{6123, 6336},
+				ccs:       heapSpace{539, 640},
+			},
+		},
+		{
+			name: "G1 on JDK 8 with a metaspace capacity",
+			out:  jdk8G1HeapInfo,
+			want: heapInfo{
+				heap:      heapSpace{19262, 262144},
+				young:     heapSpace{14336, -1},
+				eden:      heapSpace{12288, -1},
+				survivors: heapSpace{2048, -1},
+				old:       heapSpace{4926, -1},
+				meta:      heapSpace{6123, 6528},
+				ccs:       heapSpace{539, 640},
+			},
+		},
+		{
+			name: "Parallel counts both survivor spaces",
+			out:  jdk17ParallelHeapInfo,
+			want: heapInfo{
+				heap:      heapSpace{4956, 262144},
+				young:     heapSpace{3932, 87040},
+				eden:      heapSpace{3932.5625, 65536},
+				survivors: heapSpace{0, 21504},
+				old:       heapSpace{1024, 175104},
+				meta:      heapSpace{6123, 6336},
+				ccs:       heapSpace{539, 640},
+			},
+		},
+		{
+			name: "Serial",
+			out:  jdk8SerialHeapInfo,
+			want: heapInfo{
+				heap:      heapSpace{2680, 32768},
+				young:     heapSpace{2680, 10880},
+				eden:      heapSpace{2680.5, 8704},
+				survivors: heapSpace{544, 2176},
+				old:       heapSpace{0, 21888},
+				meta:      heapSpace{2698, 4864},
+				ccs:       heapSpace{288, 512},
+			},
+		},
+		{
+			name: "ZGC",
+			out:  jdk17ZgcHeapInfo,
+			want: heapInfo{
+				heap:      heapSpace{8192, 512000},
+				young:     unknown,
+				eden:      unknown,
+				survivors: unknown,
+				old:       unknown,
+				meta:      heapSpace{6123, 6336},
+				ccs:       heapSpace{539, 640},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseHeapInfo(tt.out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("parseHeapInfo() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHeapInfoWithoutHeap(t *testing.T) {
+	if _, err := parseHeapInfo("12345:\nGC.heap_info is not supported\n"); err == nil {
+		t.Error("parseHeapInfo() returned no error for output without heap sizes")
+	}
+}
+
+func TestHeapInfoFallback(t *testing.T) {
+	*heapInfoFlag = true
+	defer func() { *heapInfoFlag = false }()
+	// -gc returns data and -gcnew and -gcold fail, so GC.heap_info only
+	// fills in the metrics of the last two, and none of the disabled
+	// -gccapacity. Gather fails if a metric is collected twice.
+	fakeTools(t, map[string]fakeRun{
+		"-gc " + testPid:          jstatOutput(jdk17GcHeader, jdk17GcLine),
+		testPid + " GC.heap_info": {stdout: jdk17ParallelHeapInfo},
+	})
+	e := NewExporter(Target{JstatPath: "jstat", Pids: []string{testPid}, Modes: []string{"gc", "gcnew", "gcold"}}, "jps", nil)
+	defer e.Stop()
+	checkSamples(t, gather(t, e), map[string]float64{
+		"jstat_up":                         1,
+		"jstat_eden_used_bytes":            3932.5625 * 1024,
+		"jstat_eden_utilization_ratio":     3932.5625 / 65536,
+		"jstat_survivor_utilization_ratio": 0,
+		"jstat_old_used_bytes":             1024 * 1024,
+		"jstat_old_utilization_ratio":      1024.0 / 175104,
+		"jstat_metaspace_used_bytes":       6123 * 1024,
+		"jstat_new_capacity_bytes":         -1,
+	})
+}
+
+func TestHeapInfoWithoutHeapModes(t *testing.T) {
+	*heapInfoFlag = true
+	defer func() { *heapInfoFlag = false }()
+	tests := []struct {
+		name    string
+		options optionsFlag
+	}{
+		{name: "jstat -class fails"},
+		{name: "jstat -class and -printcompilation fail", options: optionsFlag{"printcompilation"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jstatOptions = tt.options
+			defer func() { jstatOptions = nil }()
+			// GC.heap_info has nothing to fill in for -class, so it must
+			// neither run nor count the JVM as up.
+			fakeTools(t, map[string]fakeRun{testPid + " GC.heap_info": {stdout: jdk17ParallelHeapInfo}})
+			e := NewExporter(Target{JstatPath: "jstat", Pids: []string{testPid}, Modes: []string{"class"}}, "jps", nil)
+			defer e.Stop()
+			checkSamples(t, gather(t, e), map[string]float64{
+				"jstat_up":             0,
+				"jstat_monitored_jvms": 0,
+				`jstat_command_errors_total{command="class"}`:     1,
+				`jstat_command_errors_total{command="heap_info"}`: -1,
+			})
+		})
+	}
+}
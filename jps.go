@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	osuser "os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// jvmLister is a JDK tool that lists the running JVMs one per line, each line
+// starting with the pid.
+type jvmLister struct {
+	// path is the flag with the tool's path, -jps.path or -jcmd.path.
+	path *string
+	// args returns the arguments that list the JVMs.
+	args func(remote string, matchArgs bool) ([]string, error)
+	// name returns what a target is matched against, given the fields
+	// that follow the pid on a line.
+	name func(fields []string, matchArgs bool) string
+}
+
+// jvmListers are the tools -discovery.command may name.
+var jvmListers = map[string]jvmLister{
+	"jps": {
+		path: jpsPath,
+		args: func(remote string, matchArgs bool) ([]string, error) {
+			args := []string{"-l"}
+			if matchArgs {
+				args = []string{"-lm"}
+			}
+			if remote != "" {
+				args = append(args, remote)
+			}
+			return args, nil
+		},
+		name: func(fields []string, matchArgs bool) string {
+			return strings.Join(fields, " ")
+		},
+	},
+	// jcmd without arguments prints the main class and its arguments
+	// like jps -lm, but cannot ask a jstatd.
+	"jcmd": {
+		path: jcmdPath,
+		args: func(remote string, matchArgs bool) ([]string, error) {
+			if remote != "" {
+				return nil, fmt.Errorf("jcmd cannot list the JVMs of %s", remote)
+			}
+			return nil, nil
+		},
+		name: func(fields []string, matchArgs bool) string {
+			if matchArgs {
+				return strings.Join(fields, " ")
+			}
+			return fields[0]
+		},
+	},
+}
+
+// Jps returns the pids of the JVMs listed by tool, one of jvmListers, whose
+// main class or jar matches target, in the order they are listed. See
+// matchTarget for the accepted forms of target. With matchArgs, target may be
+// any substring of the main class and its arguments instead, e.g.
+// "-Dservice.name=payments". A non-empty remote is the host:port of a jstatd
+// whose JVMs are listed instead.
+func Jps(ctx context.Context, tool string, path string, remote string, target string, matchArgs bool) ([]string, error) {
+	lister, ok := jvmListers[tool]
+	if !ok {
+		return nil, fmt.Errorf("unknown discovery command %s", tool)
+	}
+	args, err := lister.args(remote, matchArgs)
+	if err != nil {
+		return nil, err
+	}
+	out, err := toolCommand(ctx, path, args...).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var pids []string
+	for _, line := range strings.Split(string(out), "\n") {
+		items := strings.Fields(line)
+		if len(items) < 2 {
+			continue
+		}
+		name := lister.name(items[1:], matchArgs)
+		matched := matchTarget(name, target)
+		if matchArgs {
+			matched = strings.Contains(name, target)
+		}
+		if matched {
+			pids = append(pids, items[0])
+		}
+	}
+	if len(pids) == 0 {
+		return nil, fmt.Errorf("no JVM matching %s", target)
+	}
+	return pids, nil
+}
+
+// matchTarget reports whether the name printed by jps -l is the target. The
+// target may be the fully qualified class name, the short class name, or any
+// trailing part of either such as "startup.Bootstrap" or "app.jar".
+func matchTarget(name string, target string) bool {
+	return name == target ||
+		strings.HasSuffix(name, "."+target) ||
+		strings.HasSuffix(name, "/"+target)
+}
+
+// lookupTool finds a JDK tool such as jstat or jps when its path flag is not
+// given: $JAVA_HOME/bin first, then the default path, then $PATH.
+func lookupTool(name string, defaultPath string) (string, error) {
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	if javaHome := os.Getenv("JAVA_HOME"); javaHome != "" {
+		path := filepath.Join(javaHome, "bin", name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	if _, err := os.Stat(defaultPath); err == nil {
+		return defaultPath, nil
+	}
+	if path, err := exec.LookPath(name); err == nil {
+		return path, nil
+	}
+	return defaultPath, fmt.Errorf("%s not found in $JAVA_HOME/bin, %s or $PATH", name, defaultPath)
+}
+
+// checkExecutable returns an error unless path is an executable file.
+func checkExecutable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	if runtime.GOOS != "windows" && info.Mode()&0111 == 0 {
+		return fmt.Errorf("%s is not executable", path)
+	}
+	return nil
+}
+
+// ownedBy returns the pids whose process belongs to user, given as a name or
+// a numeric uid.
+func ownedBy(ctx context.Context, pids []string, user string) []string {
+	var owned []string
+	for _, pid := range pids {
+		uid, name, err := pidOwner(ctx, pid)
+		if err != nil {
+			slog.Warn("Failed to find the owner of pid", "pid", pid, "err", err)
+			continue
+		}
+		if uid == user || name == user {
+			owned = append(owned, pid)
+		}
+	}
+	return owned
+}
+
+// pidOwner returns the uid and user name owning pid, from /proc where there is
+// one and from ps otherwise.
+func pidOwner(ctx context.Context, pid string) (string, string, error) {
+	status, err := os.ReadFile(filepath.Join("/proc", pid, "status"))
+	if err != nil {
+		out, err := execCommand(ctx, "ps", "-o", "uid=,user=", "-p", pid).Output()
+		if err != nil {
+			return "", "", err
+		}
+		fields := strings.Fields(string(out))
+		if len(fields) != 2 {
+			return "", "", fmt.Errorf("unexpected ps output %q", out)
+		}
+		return fields[0], fields[1], nil
+	}
+	for _, line := range strings.Split(string(status), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "Uid:" {
+			continue
+		}
+		uid := fields[1]
+		if u, err := osuser.LookupId(uid); err == nil {
+			return uid, u.Username, nil
+		}
+		return uid, "", nil
+	}
+	return "", "", fmt.Errorf("no Uid in /proc/%s/status", pid)
+}
+
+// readPidFile returns the pid a launcher or supervisor wrote to path.
+func readPidFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	pid := strings.TrimSpace(string(content))
+	if _, err := strconv.Atoi(pid); err != nil {
+		return "", fmt.Errorf("no pid in %s: %q", path, content)
+	}
+	return pid, nil
+}
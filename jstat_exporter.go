@@ -1,296 +1,2448 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/http/pprof"
+	"os"
 	"os/exec"
+	"os/signal"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/log"
-)
-
-const (
-	namespace = "jstat"
+	versioncollector "github.com/prometheus/client_golang/prometheus/collectors/version"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/common/version"
 )
 
 var (
-	listenAddress = flag.String("web.listen-address", ":9010", "Address on which to expose metrics and web interface.")
-	metricsPath   = flag.String("web.telemetry-path", "/metrics", "Path under which to expose metrics.")
-	jstatPath     = flag.String("jstat.path", "/usr/bin/jstat", "jstat path")
-	targetPid     = flag.String("target.pid", ":0", "target pid")
+	logLevel         = flag.String("log.level", "info", "Only log messages with the given severity or above. Valid levels: [debug, info, warn, error].")
+	logFormat        = flag.String("log.format", "logfmt", "Log output format. Valid formats: [logfmt, json].")
+	oneshotMode      = flag.Bool("oneshot", false, "Collect every target once, print the jstat output and metrics to stdout and exit. Exits with 1 when a target is not healthy.")
+	showVersion      = flag.Bool("version", false, "Print version information and exit.")
+	namespace        = flag.String("metric.namespace", "jstat", "Prefix of all exported metric names.")
+	metricBytes      = flag.Bool("metric.bytes", false, "Export sizes in bytes instead of kB together with -metric.legacy-names.")
+	legacyNames      = flag.Bool("metric.legacy-names", false, "Export the camelCase metric names and kB values of earlier releases.")
+	subsystem        = flag.String("metric.subsystem", "", "Optional name inserted between the namespace and the metric name.")
+	listenAddress    = flag.String("web.listen-address", ":9010", "Address on which to expose metrics and web interface, or unix:<path> to listen on a Unix domain socket.")
+	metricsPath      = flag.String("web.telemetry-path", "/metrics", "Path under which to expose metrics.")
+	authUser         = flag.String("web.auth-user", "", "Username for HTTP basic authentication. Authentication is disabled when empty.")
+	authPassword     = flag.String("web.auth-password", "", "Password for HTTP basic authentication.")
+	authPassFile     = flag.String("web.auth-password-file", "", "File containing the password for HTTP basic authentication. Overrides -web.auth-password.")
+	noDefaultMetrics = flag.Bool("web.disable-default-metrics", false, "Only export jstat metrics, without the go_* and process_* metrics of the exporter itself.")
+	enablePprof      = flag.Bool("web.enable-pprof", false, "Serve Go profiling data under /debug/pprof/.")
+	readTimeout      = flag.Duration("web.read-timeout", 10*time.Second, "Maximum time to read an HTTP request, including its body.")
+	writeTimeout     = flag.Duration("web.write-timeout", 2*time.Minute, "Maximum time to write an HTTP response. Must leave room for a scrape to run every jstat command.")
+	idleTimeout      = flag.Duration("web.idle-timeout", 2*time.Minute, "Maximum time to keep an idle keep-alive connection open.")
+	healthMaxAge     = flag.Duration("web.health-max-age", time.Minute, "Age of the last scrape after which /healthz collects the targets itself instead of answering from it.")
+	shutdownTimeout  = flag.Duration("web.shutdown-timeout", 20*time.Second, "Maximum time to let running scrapes finish on SIGTERM before their jstat commands are killed.")
+	tlsCert          = flag.String("web.tls-cert", "", "Path to a PEM certificate. Serves HTTPS; must be set together with -web.tls-key.")
+	tlsKey           = flag.String("web.tls-key", "", "Path to the PEM private key for -web.tls-cert.")
+	tlsClientCA      = flag.String("web.tls-client-ca", "", "Path to a PEM CA bundle. When set, clients must present a certificate signed by it. Needs -web.tls-cert and -web.tls-key.")
+	pushGateway      = flag.String("push.gateway", "", "URL of a Pushgateway to push metrics to, in addition to serving them. Disabled when empty.")
+	pushJob          = flag.String("push.job", "jstat", "Job name metrics are pushed under.")
+	pushInterval     = flag.Duration("push.interval", 15*time.Second, "Interval between pushes to -push.gateway.")
+	configFile       = flag.String("config.file", "", "YAML file listing the targets to monitor. The -target and -jstat.path flags are used when not set.")
+	jstatPath        = flag.String("jstat.path", defaultJstatPath, "jstat path. When not given, $JAVA_HOME/bin/jstat and $PATH are tried as well.")
+	skipValidation   = flag.Bool("jstat.skip-validation", false, "Do not check at startup that jstat and jps exist and are executable. Tools that are not found are run from their default paths.")
+	jstatTimeout     = flag.Duration("jstat.timeout", 5*time.Second, "Time after which a hanging jstat is killed and its metrics are left out of the scrape.")
+	extraColumns     = flag.Bool("collect.extra-columns", false, "Also export every numeric column of each mode as jstat_<mode>_<column>, unscaled, including columns without a metric of their own.")
+	jstatTime        = flag.Bool("jstat.timestamp", false, "Run jstat with -t and export the uptime of the target JVM.")
+	jcmdPath         = flag.String("jcmd.path", defaultJcmdPath, "jcmd path, for -jcmd.heap-info and -discovery.command=jcmd. When not given, $JAVA_HOME/bin/jcmd and $PATH are tried as well.")
+	heapInfoFlag     = flag.Bool("jcmd.heap-info", false, "Fill in the metrics of jstat -gc, -gcnew, -gcold and -gccapacity from jcmd <pid> GC.heap_info when those modes return no data for a JVM. Ignored with -target.remote.")
+	discovery        = flag.String("discovery.command", "jps", "Tool that lists the JVMs to resolve -target against: jps or jcmd, for JREs that ship jcmd without jps. It is run from -jps.path or -jcmd.path.")
+	jpsPath          = flag.String("jps.path", defaultJpsPath, "jps path. When not given, $JAVA_HOME/bin/jps and $PATH are tried as well.")
+	jpsTimeout       = flag.Duration("jps.timeout", 5*time.Second, "Time after which a hanging -discovery.command is killed.")
+	target           = flag.String("target", "", "Main class or jar of the target JVM, resolved with jps. Several comma-separated names are exported with a target label. Overrides -target.pid and -target.pidfile when set.")
+	matchArgs        = flag.Bool("target.match-args", false, "Match -target against the main class and arguments reported by jps -lm.")
+	targetRemote     = flag.String("target.remote", "", "host:port of a jstatd to monitor remote JVMs through. Local JVMs are monitored when empty.")
+	allMatches       = flag.Bool("target.all", false, "Monitor every JVM matching -target instead of only the first one.")
+	targetUser       = flag.String("target.user", "", "Only monitor JVMs matching -target that run as this user name or uid. Ignored with -target.remote.")
+	targetPidFile    = flag.String("target.pidfile", "", "File the target pid is read from on every scrape, e.g. one written by a launcher. Overrides -target.pid when set.")
+	targetPid        = flag.String("target.pid", "", "Comma-separated list of target pids, attached to directly without jps. Pids that are not running are skipped until they are.")
 )
 
+// collectModes are the -collect.<mode> flags.
+var collectModes = make(map[string]*bool)
+
+// metricLabels are the -metric.label pairs added to every metric.
+var metricLabels = labelsFlag{}
+
+// pushLabels are the -push.label pairs added to the grouping key for pushes.
+var pushLabels = labelsFlag{}
+
+// jstatOptions are the -jstat.option flags, without the leading dash.
+var jstatOptions optionsFlag
+
+// modeIntervals are the -interval.<mode> flags.
+var modeIntervals = make(map[string]*time.Duration)
+
+func init() {
+	for _, mode := range jstatModes {
+		collectModes[mode.name] = flag.Bool("collect."+mode.name, true, "Run jstat -"+mode.name+" and export its metrics.")
+		modeIntervals[mode.name] = flag.Duration("interval."+mode.name, 0, "Minimum time between jstat -"+mode.name+" runs. Scrapes in between reuse the last sample; 0 runs it on every scrape.")
+	}
+	flag.Var(metricLabels, "metric.label", "Constant label added to every metric, as name=value. May be repeated.")
+	flag.Var(&jstatOptions, "jstat.option", "Additional jstat option such as printcompilation whose numeric columns are exported as jstat_<option>_<column>, unscaled. May be repeated.")
+	flag.Var(pushLabels, "push.label", "Grouping label for pushes besides instance=<hostname>, as name=value. May be repeated.")
+}
+
+// jstatModes lists the jstat options the exporter knows how to collect, in
+// the order they are collected.
+var jstatModes = []struct {
+	name    string
+	collect func(e *Exporter, ch chan<- prometheus.Metric, pid string) error
+}{
+	{"gccapacity", (*Exporter).JstatGccapacity},
+	{"gcold", (*Exporter).JstatGcold},
+	{"gcnew", (*Exporter).JstatGcnew},
+	{"gc", (*Exporter).JstatGc},
+	{"gcutil", (*Exporter).JstatGcutil},
+	{"gccause", (*Exporter).JstatGccause},
+	{"class", (*Exporter).JstatClass},
+	{"compiler", (*Exporter).JstatCompiler},
+	{"gcnewcapacity", (*Exporter).JstatGcnewcapacity},
+	{"gcoldcapacity", (*Exporter).JstatGcoldcapacity},
+	{"gcmetacapacity", (*Exporter).JstatGcmetacapacity},
+}
+
+// kBColumns are the jstat columns reported in kB. They are exported in bytes
+// unless -metric.legacy-names is set without -metric.bytes.
+var kBColumns = map[string]bool{
+	"S0C": true, "S1C": true, "S0U": true, "S1U": true, "EC": true, "EU": true,
+	"OC": true, "OU": true, "MC": true, "MU": true, "CCSC": true, "CCSU": true,
+	"NGCMN": true, "NGCMX": true, "NGC": true, "OGCMN": true, "OGCMX": true, "OGC": true,
+	"MCMN": true, "MCMX": true, "CCSMN": true, "CCSMX": true,
+	"S0CMX": true, "S1CMX": true, "ECMX": true, "DSS": true,
+	"PC": true, "PU": true, "PGCMN": true, "PGCMX": true, "PGC": true,
+	"Bytes": true, "Bytes2": true,
+}
+
+// metricName returns legacy when -metric.legacy-names is set and name
+// otherwise.
+func metricName(legacy string, name string) string {
+	if *legacyNames {
+		return legacy
+	}
+	return name
+}
+
+// invalidNameChars matches what a jstat column name may contain that a metric
+// name may not.
+var invalidNameChars = regexp.MustCompile("[^a-zA-Z0-9_]")
+
+// execCommand builds the jstat and jps commands. It is a variable so that
+// canned output can be substituted for a real JVM.
+var execCommand = exec.CommandContext
+
+// toolCommand builds a jstat or jps command. It runs in the C locale, since
+// the JDK tools otherwise print numbers with the decimal comma of locales
+// such as de_DE.
+func toolCommand(ctx context.Context, path string, args ...string) *exec.Cmd {
+	cmd := execCommand(ctx, path, args...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	return cmd
+}
+
+// errUnavailable is returned by Exporter.jstat while jstat cannot read the
+// target's performance data, which happens while a JVM starts or is under
+// heavy load. It is transient, so Collect skips the mode without an error.
+var errUnavailable = errors.New("process information unavailable")
+
+// valueMetric is a single gauge or counter that is set straight from jstat.
+type valueMetric interface {
+	Set(float64)
+	Collect(chan<- prometheus.Metric)
+}
+
+// counterValue is a valueMetric for a running total that jstat reports, such
+// as YGC. A counter cannot be set, so it is collected as a const metric.
+type counterValue struct {
+	desc        *prometheus.Desc
+	labelValues []string
+	value       float64
+	sampled     time.Time
+}
+
+// counter returns a counterValue of desc for pid.
+func counter(desc *prometheus.Desc, pid string) *counterValue {
+	return &counterValue{desc: desc, labelValues: []string{pid}}
+}
+
+// exemplarCounter returns a counterValue of desc for pid that is collected
+// with an exemplar of the jstat sample taken at sampled. jstat knows nothing
+// of traces, so the exemplar has no labels for now; it is only exposed when
+// the scraper negotiates OpenMetrics.
+func exemplarCounter(desc *prometheus.Desc, pid string, sampled time.Time) *counterValue {
+	return &counterValue{desc: desc, labelValues: []string{pid}, sampled: sampled}
+}
+
+// Set implements valueMetric.
+func (c *counterValue) Set(value float64) {
+	c.value = value
+}
+
+// Collect implements valueMetric.
+func (c *counterValue) Collect(ch chan<- prometheus.Metric) {
+	m := prometheus.MustNewConstMetric(c.desc, prometheus.CounterValue, c.value, c.labelValues...)
+	if !c.sampled.IsZero() {
+		m = prometheus.MustNewMetricWithExemplars(m, prometheus.Exemplar{Value: c.value, Timestamp: c.sampled})
+	}
+	ch <- m
+}
+
+// jstatRun identifies a jstat option run against a pid.
+type jstatRun struct {
+	pid    string
+	option string
+}
+
+// jstatResult is the output of the last successful run of a jstatRun.
+type jstatResult struct {
+	time   time.Time
+	header string
+	line   string
+}
+
+// gcTime is the GCT column of a -gc sample and when the sample was taken.
+// overhead is the GC share of the time since the previous sample, or -1
+// while there is no previous sample to compare with.
+type gcTime struct {
+	time     time.Time
+	seconds  float64
+	overhead float64
+}
+
+// Exporter collects the jstat modes of one target. It is a single collector
+// rather than one per mode because a scrape resolves the target's pids once
+// and then needs the results of every mode together: jstat_up and
+// jstat_monitored_jvms depend on whether any mode returned data, the heap and
+// metaspace utilization combine -gc or -gcold with -gccapacity, and jcmd
+// GC.heap_info only fills in the modes that failed.
 type Exporter struct {
-	jstatPath  string
-	targetPid  string
-	newMax     prometheus.Gauge
-	newCommit  prometheus.Gauge
-	oldMax     prometheus.Gauge
-	oldCommit  prometheus.Gauge
-	metaMax    prometheus.Gauge
-	metaCommit prometheus.Gauge
-	metaUsed   prometheus.Gauge
-	oldUsed    prometheus.Gauge
-	sv0Used    prometheus.Gauge
-	sv1Used    prometheus.Gauge
-	edenUsed   prometheus.Gauge
-	fgcTimes   prometheus.Counter
-	fgcSec     prometheus.Gauge
-}
-
-func NewExporter(jstatPath string, targetPid string) *Exporter {
-	return &Exporter{
-		jstatPath: jstatPath,
-		targetPid: targetPid,
-		newMax: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "newMax",
-			Help:      "newMax",
-		}),
-		newCommit: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "newCommit",
-			Help:      "newCommit",
-		}),
-		oldMax: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "oldMax",
-			Help:      "oldMax",
-		}),
-		oldCommit: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "oldCommit",
-			Help:      "oldCommit",
-		}),
-		metaMax: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "metaMax",
-			Help:      "metaMax",
-		}),
-		metaCommit: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "metaCommit",
-			Help:      "metaCommit",
-		}),
-		metaUsed: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "metaUsed",
-			Help:      "metaUsed",
-		}),
-		oldUsed: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "oldUsed",
-			Help:      "oldUsed",
+	jstatPath            string
+	discovery            string
+	jpsPath              string
+	jpsTimeout           time.Duration
+	jcmdPath             string
+	heapInfo             bool
+	jstatTimeout         time.Duration
+	target               string
+	matchArgs            bool
+	allMatches           bool
+	user                 string
+	remote               string
+	targetPids           []string
+	pidFile              string
+	modes                []string
+	interval             time.Duration
+	options              []string
+	timestamp            bool
+	bytes                bool
+	extraColumns         bool
+	constLabels          prometheus.Labels
+	newMin               *prometheus.GaugeVec
+	newMax               *prometheus.GaugeVec
+	newCommit            *prometheus.GaugeVec
+	oldMin               *prometheus.GaugeVec
+	oldMax               *prometheus.GaugeVec
+	oldCommit            *prometheus.GaugeVec
+	oldCurrent           *prometheus.GaugeVec
+	metaMax              *prometheus.GaugeVec
+	metaCommit           *prometheus.GaugeVec
+	metaMin              *prometheus.GaugeVec
+	ccsMin               *prometheus.GaugeVec
+	ccsMax               *prometheus.GaugeVec
+	ccsCurrent           *prometheus.GaugeVec
+	metaUsed             *prometheus.GaugeVec
+	oldUsed              *prometheus.GaugeVec
+	permGenCapacity      *prometheus.GaugeVec
+	permGenUsed          *prometheus.GaugeVec
+	permGenMin           *prometheus.GaugeVec
+	permGenMax           *prometheus.GaugeVec
+	permGenCommit        *prometheus.GaugeVec
+	sv0Used              *prometheus.GaugeVec
+	sv1Used              *prometheus.GaugeVec
+	edenUsed             *prometheus.GaugeVec
+	gcnewSv0Capacity     *prometheus.GaugeVec
+	gcnewSv1Capacity     *prometheus.GaugeVec
+	edenUtilization      *prometheus.GaugeVec
+	survivorUtilization  *prometheus.GaugeVec
+	oldUtilization       *prometheus.GaugeVec
+	tenuringThreshold    *prometheus.GaugeVec
+	maxTenuringThreshold *prometheus.GaugeVec
+	desiredSurvivorSize  *prometheus.GaugeVec
+	sv0Capacity          *prometheus.GaugeVec
+	sv1Capacity          *prometheus.GaugeVec
+	edenCapacity         *prometheus.GaugeVec
+	oldCapacity          *prometheus.GaugeVec
+	ccsCapacity          *prometheus.GaugeVec
+	ccsUsed              *prometheus.GaugeVec
+	ygcTimes             *prometheus.Desc
+	ygcSec               *prometheus.Desc
+	fgcTimes             *prometheus.Desc
+	fgcSec               *prometheus.Desc
+	cgcTimes             *prometheus.Desc
+	cgcSec               *prometheus.Desc
+	gcTotalSec           *prometheus.Desc
+	gcOverhead           *prometheus.GaugeVec
+	heapUsed             *prometheus.GaugeVec
+	heapCommitted        *prometheus.GaugeVec
+	heapUtilization      *prometheus.GaugeVec
+	metaUtilization      *prometheus.GaugeVec
+	survivor0Pct         *prometheus.GaugeVec
+	survivor1Pct         *prometheus.GaugeVec
+	edenPct              *prometheus.GaugeVec
+	oldPct               *prometheus.GaugeVec
+	metaPct              *prometheus.GaugeVec
+	ccsPct               *prometheus.GaugeVec
+	lastGcCause          *prometheus.GaugeVec
+	currentGcCause       *prometheus.GaugeVec
+	classesLoaded        *prometheus.Desc
+	classBytesLoaded     *prometheus.Desc
+	classesUnloaded      *prometheus.Desc
+	classBytesUnloaded   *prometheus.Desc
+	classLoadSec         *prometheus.Desc
+	compilerTasks        *prometheus.Desc
+	compilerFailed       *prometheus.Desc
+	compilerInvalid      *prometheus.Desc
+	compilerSec          *prometheus.Desc
+	compilerFailedMethod *prometheus.GaugeVec
+	sv0CapacityMax       *prometheus.GaugeVec
+	sv1CapacityMax       *prometheus.GaugeVec
+	edenCapacityMax      *prometheus.GaugeVec
+	oldCapacityMin       *prometheus.GaugeVec
+	oldCapacityMax       *prometheus.GaugeVec
+	oldCapacityCurrent   *prometheus.GaugeVec
+	oldCapacityCommitted *prometheus.GaugeVec
+	metaCapacityMin      *prometheus.GaugeVec
+	metaCapacityMax      *prometheus.GaugeVec
+	metaCapacityCurrent  *prometheus.GaugeVec
+	ccsCapacityMin       *prometheus.GaugeVec
+	ccsCapacityMax       *prometheus.GaugeVec
+	ccsCapacityCurrent   *prometheus.GaugeVec
+	up                   prometheus.Gauge
+	monitoredJvms        prometheus.Gauge
+	targetPidGauge       prometheus.Gauge
+	lastScrapeTimestamp  *prometheus.GaugeVec
+	sampleInterval       *prometheus.GaugeVec
+	commandInfo          *prometheus.GaugeVec
+	parseErrors          prometheus.Counter
+	commandErrors        *prometheus.CounterVec
+	scrapeDuration       prometheus.Gauge
+	scrapeErrors         prometheus.Counter
+	jvmUptime            *prometheus.GaugeVec
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	// running is read-locked for as long as a Collect runs commands, so
+	// Stop can wait for the ones it killed to exit.
+	running sync.RWMutex
+
+	mu          sync.Mutex
+	lastUp      bool
+	lastCollect time.Time
+	lastScrape  map[jstatRun]jstatResult
+	gcTimes     map[string]gcTime
+	resolved    []string
+}
+
+// NewExporter returns an exporter for the JVMs described by t. constLabels
+// are added to every metric, which lets several exporters share a registry.
+func NewExporter(t Target, jpsPath string, constLabels prometheus.Labels) *Exporter {
+	ctx, cancel := context.WithCancel(context.Background())
+	inBytes := !*legacyNames || *metricBytes
+	// size is the unit of the sizes, for the help strings.
+	size := "kB"
+	if inBytes {
+		size = "bytes"
+	}
+	e := &Exporter{
+		ctx:          ctx,
+		cancel:       cancel,
+		jstatPath:    t.JstatPath,
+		discovery:    *discovery,
+		jpsPath:      jpsPath,
+		jpsTimeout:   *jpsTimeout,
+		jcmdPath:     *jcmdPath,
+		heapInfo:     *heapInfoFlag && t.Remote == "",
+		jstatTimeout: *jstatTimeout,
+		target:       t.Name,
+		matchArgs:    t.MatchArgs,
+		allMatches:   t.AllMatches,
+		user:         t.User,
+		remote:       t.Remote,
+		targetPids:   t.Pids,
+		pidFile:      t.PidFile,
+		modes:        t.Modes,
+		interval:     t.Interval,
+		options:      jstatOptions,
+		timestamp:    t.Timestamp,
+		bytes:        inBytes,
+		extraColumns: *extraColumns,
+		constLabels:  constLabels,
+		lastScrape:   make(map[jstatRun]jstatResult),
+		gcTimes:      make(map[string]gcTime),
+		newMin: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("newMin", "new_capacity_min_bytes"),
+			Help:        "Minimum new generation capacity in " + size + " (NGCMN of jstat -gccapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		newMax: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("newMax", "new_capacity_max_bytes"),
+			Help:        "Maximum new generation capacity in " + size + " (NGCMX of jstat -gccapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		newCommit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("newCommit", "new_capacity_bytes"),
+			Help:        "Current new generation capacity in " + size + " (NGC of jstat -gccapacity, or jcmd GC.heap_info with -jcmd.heap-info).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		oldMin: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("oldMin", "old_gen_capacity_min_bytes"),
+			Help:        "Minimum old generation capacity in " + size + " (OGCMN of jstat -gccapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		oldMax: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("oldMax", "old_gen_capacity_max_bytes"),
+			Help:        "Maximum old generation capacity in " + size + " (OGCMX of jstat -gccapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		oldCommit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("oldCommit", "old_gen_capacity_bytes"),
+			Help:        "Current old generation capacity in " + size + " (OGC of jstat -gccapacity, or jcmd GC.heap_info with -jcmd.heap-info).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		oldCurrent: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("oldCurrent", "gccapacity_old_capacity_bytes"),
+			Help:        "Current old space capacity in " + size + " (OC of jstat -gccapacity, or jcmd GC.heap_info with -jcmd.heap-info).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		metaMax: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("metaMax", "metaspace_capacity_max_bytes"),
+			Help:        "Maximum metaspace capacity in " + size + " (MCMX of jstat -gccapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		metaCommit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("metaCommit", "metaspace_capacity_bytes"),
+			Help:        "Metaspace capacity in " + size + " (MC of jstat -gccapacity, or jcmd GC.heap_info with -jcmd.heap-info).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		metaMin: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("metaMin", "metaspace_capacity_min_bytes"),
+			Help:        "Minimum metaspace capacity in " + size + " (MCMN of jstat -gccapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		ccsMin: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("ccsMin", "ccs_capacity_min_bytes"),
+			Help:        "Minimum compressed class space capacity in " + size + " (CCSMN of jstat -gccapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		ccsMax: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("ccsMax", "ccs_capacity_max_bytes"),
+			Help:        "Maximum compressed class space capacity in " + size + " (CCSMX of jstat -gccapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		ccsCurrent: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("ccsCurrent", "gccapacity_ccs_capacity_bytes"),
+			Help:        "Compressed class space capacity in " + size + " (CCSC of jstat -gccapacity, or jcmd GC.heap_info with -jcmd.heap-info).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		metaUsed: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("metaUsed", "metaspace_used_bytes"),
+			Help:        "Used metaspace in " + size + " (MU of jstat -gcold, or jcmd GC.heap_info with -jcmd.heap-info).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		oldUsed: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("oldUsed", "old_used_bytes"),
+			Help:        "Used old space in " + size + " (OU of jstat -gcold, or jcmd GC.heap_info with -jcmd.heap-info).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		permGenCapacity: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("permGenCapacity", "permgen_capacity_bytes"),
+			Help:        "Current permanent space capacity in " + size + " (PC of jstat -gcold).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		permGenUsed: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("permGenUsed", "permgen_used_bytes"),
+			Help:        "Used permanent space in " + size + " (PU of jstat -gcold).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		permGenMin: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("permGenMin", "permgen_capacity_min_bytes"),
+			Help:        "Minimum permanent generation capacity in " + size + " (PGCMN of jstat -gccapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		permGenMax: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("permGenMax", "permgen_capacity_max_bytes"),
+			Help:        "Maximum permanent generation capacity in " + size + " (PGCMX of jstat -gccapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		permGenCommit: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("permGenCommit", "permgen_generation_capacity_bytes"),
+			Help:        "Current permanent generation capacity in " + size + " (PGC of jstat -gccapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		sv0Used: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("sv0Used", "survivor0_used_bytes"),
+			Help:        "Used survivor space 0 in " + size + " (S0U of jstat -gcnew).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		sv1Used: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("sv1Used", "survivor1_used_bytes"),
+			Help:        "Used survivor space 1 in " + size + " (S1U of jstat -gcnew).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		edenUsed: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("edenUsed", "eden_used_bytes"),
+			Help:        "Used eden space in " + size + " (EU of jstat -gcnew, or jcmd GC.heap_info with -jcmd.heap-info).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		gcnewSv0Capacity: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("gcnewSv0Capacity", "gcnew_survivor0_capacity_bytes"),
+			Help:        "Current survivor space 0 capacity in " + size + " (S0C of jstat -gcnew).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		gcnewSv1Capacity: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("gcnewSv1Capacity", "gcnew_survivor1_capacity_bytes"),
+			Help:        "Current survivor space 1 capacity in " + size + " (S1C of jstat -gcnew).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		edenUtilization: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("edenUtilization", "eden_utilization_ratio"),
+			Help:        "Used share of the current eden space capacity as a ratio from 0 to 1 (EU/EC of jstat -gcnew, or jcmd GC.heap_info with -jcmd.heap-info).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		survivorUtilization: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("survivorUtilization", "survivor_utilization_ratio"),
+			Help:        "Used share of the current survivor space capacity as a ratio from 0 to 1 ((S0U+S1U)/(S0C+S1C) of jstat -gcnew, or jcmd GC.heap_info with -jcmd.heap-info).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		oldUtilization: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("oldUtilization", "old_utilization_ratio"),
+			Help:        "Used share of the current old space capacity as a ratio from 0 to 1 (OU/OC of jstat -gcold, or jcmd GC.heap_info with -jcmd.heap-info).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		tenuringThreshold: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("tenuringThreshold", "tenuring_threshold"),
+			Help:        "Tenuring threshold in young GC cycles survived (TT of jstat -gcnew).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		maxTenuringThreshold: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("maxTenuringThreshold", "max_tenuring_threshold"),
+			Help:        "Maximum tenuring threshold in young GC cycles survived (MTT of jstat -gcnew).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		desiredSurvivorSize: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("desiredSurvivorSize", "desired_survivor_size_bytes"),
+			Help:        "Desired survivor size in " + size + " (DSS of jstat -gcnew).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		sv0Capacity: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("sv0Capacity", "survivor0_capacity_bytes"),
+			Help:        "Current survivor space 0 capacity in " + size + " (S0C of jstat -gc).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		sv1Capacity: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("sv1Capacity", "survivor1_capacity_bytes"),
+			Help:        "Current survivor space 1 capacity in " + size + " (S1C of jstat -gc).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		edenCapacity: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("edenCapacity", "eden_capacity_bytes"),
+			Help:        "Current eden space capacity in " + size + " (EC of jstat -gc, or jcmd GC.heap_info with -jcmd.heap-info).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		oldCapacity: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("oldCapacity", "old_capacity_bytes"),
+			Help:        "Current old space capacity in " + size + " (OC of jstat -gc, or jcmd GC.heap_info with -jcmd.heap-info).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		ccsCapacity: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("ccsCapacity", "ccs_capacity_bytes"),
+			Help:        "Compressed class space capacity in " + size + " (CCSC of jstat -gc, or jcmd GC.heap_info with -jcmd.heap-info).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		ccsUsed: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("ccsUsed", "ccs_used_bytes"),
+			Help:        "Used compressed class space in " + size + " (CCSU of jstat -gc, or jcmd GC.heap_info with -jcmd.heap-info).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		ygcTimes: prometheus.NewDesc(
+			prometheus.BuildFQName(*namespace, *subsystem, metricName("ygcTimes", "young_gc_count_total")),
+			"Number of young generation GC events (YGC of jstat -gc).",
+			[]string{"pid"}, constLabels,
+		),
+		ygcSec: prometheus.NewDesc(
+			prometheus.BuildFQName(*namespace, *subsystem, metricName("ygcSec", "young_gc_time_seconds_total")),
+			"Young generation garbage collection time in seconds (YGCT of jstat -gc).",
+			[]string{"pid"}, constLabels,
+		),
+		fgcTimes: prometheus.NewDesc(
+			prometheus.BuildFQName(*namespace, *subsystem, metricName("fgcTimes", "full_gc_count_total")),
+			"Number of full GC events (FGC of jstat -gc).",
+			[]string{"pid"}, constLabels,
+		),
+		fgcSec: prometheus.NewDesc(
+			prometheus.BuildFQName(*namespace, *subsystem, metricName("fgcSec", "full_gc_time_seconds_total")),
+			"Full garbage collection time in seconds (FGCT of jstat -gc).",
+			[]string{"pid"}, constLabels,
+		),
+		cgcTimes: prometheus.NewDesc(
+			prometheus.BuildFQName(*namespace, *subsystem, metricName("cgcTimes", "concurrent_gc_count_total")),
+			"Number of concurrent GC events (CGC of jstat -gc, JDK 11+).",
+			[]string{"pid"}, constLabels,
+		),
+		cgcSec: prometheus.NewDesc(
+			prometheus.BuildFQName(*namespace, *subsystem, metricName("cgcSec", "concurrent_gc_time_seconds_total")),
+			"Concurrent garbage collection time in seconds (CGCT of jstat -gc, JDK 11+).",
+			[]string{"pid"}, constLabels,
+		),
+		gcTotalSec: prometheus.NewDesc(
+			prometheus.BuildFQName(*namespace, *subsystem, metricName("gcTotalSec", "gc_time_seconds_total")),
+			"Total garbage collection time in seconds (GCT of jstat -gc).",
+			[]string{"pid"}, constLabels,
+		),
+		gcOverhead: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("gcOverhead", "gc_overhead_ratio"),
+			Help:        "Share of wall-clock time spent in GC between the last two -gc samples, as a ratio from 0 to 1.",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		heapUsed: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("heapUsed", "heap_used_bytes"),
+			Help:        "Used heap in " + size + " (S0U+S1U+EU+OU of jstat -gc, or jcmd GC.heap_info with -jcmd.heap-info).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		heapCommitted: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("heapCommitted", "heap_committed_bytes"),
+			Help:        "Current heap capacity in " + size + " (S0C+S1C+EC+OC of jstat -gc, or jcmd GC.heap_info with -jcmd.heap-info).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		heapUtilization: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("heapUtilization", "heap_utilization_ratio"),
+			Help:        "Used share of the maximum heap as a ratio from 0 to 1 (S0U+S1U+EU+OU of jstat -gc over NGCMX+OGCMX of jstat -gccapacity, or OGCMX alone under G1).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		metaUtilization: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("metaUtilization", "metaspace_utilization_ratio"),
+			Help:        "Used share of the maximum metaspace as a ratio from 0 to 1 (MU of jstat -gcold over MCMX of jstat -gccapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		survivor0Pct: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("survivor0Pct", "survivor0_used_percent"),
+			Help:        "Survivor space 0 utilization as a percentage of the space's current capacity (S0 of jstat -gcutil).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		survivor1Pct: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("survivor1Pct", "survivor1_used_percent"),
+			Help:        "Survivor space 1 utilization as a percentage of the space's current capacity (S1 of jstat -gcutil).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		edenPct: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("edenPct", "eden_used_percent"),
+			Help:        "Eden space utilization as a percentage of the space's current capacity (E of jstat -gcutil).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		oldPct: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("oldPct", "old_used_percent"),
+			Help:        "Old space utilization as a percentage of the space's current capacity (O of jstat -gcutil).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		metaPct: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("metaPct", "metaspace_used_percent"),
+			Help:        "Metaspace utilization as a percentage of the space's current capacity (M of jstat -gcutil).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		ccsPct: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("ccsPct", "ccs_used_percent"),
+			Help:        "Compressed class space utilization as a percentage of the space's current capacity (CCS of jstat -gcutil).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		lastGcCause: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("lastGcCause", "last_gc_cause"),
+			Help:        "Always 1, labeled with the cause of the last garbage collection (LGCC of jstat -gccause).",
+			ConstLabels: constLabels,
+		}, []string{"pid", "cause"}),
+		currentGcCause: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("currentGcCause", "current_gc_cause"),
+			Help:        "Always 1, labeled with the cause of the current garbage collection (GCC of jstat -gccause).",
+			ConstLabels: constLabels,
+		}, []string{"pid", "cause"}),
+		classesLoaded: prometheus.NewDesc(
+			prometheus.BuildFQName(*namespace, *subsystem, metricName("classesLoaded", "classes_loaded_total")),
+			"Number of classes loaded (Loaded of jstat -class).",
+			[]string{"pid"}, constLabels,
+		),
+		classBytesLoaded: prometheus.NewDesc(
+			prometheus.BuildFQName(*namespace, *subsystem, metricName("classBytesLoaded", "class_loaded_bytes_total")),
+			"Size of the classes loaded in "+size+" (Bytes of jstat -class).",
+			[]string{"pid"}, constLabels,
+		),
+		classesUnloaded: prometheus.NewDesc(
+			prometheus.BuildFQName(*namespace, *subsystem, metricName("classesUnloaded", "classes_unloaded_total")),
+			"Number of classes unloaded (Unloaded of jstat -class).",
+			[]string{"pid"}, constLabels,
+		),
+		classBytesUnloaded: prometheus.NewDesc(
+			prometheus.BuildFQName(*namespace, *subsystem, metricName("classBytesUnloaded", "class_unloaded_bytes_total")),
+			"Size of the classes unloaded in "+size+" (the second Bytes of jstat -class).",
+			[]string{"pid"}, constLabels,
+		),
+		classLoadSec: prometheus.NewDesc(
+			prometheus.BuildFQName(*namespace, *subsystem, metricName("classLoadSec", "class_load_time_seconds_total")),
+			"Time spent performing class loading and unloading operations in seconds (Time of jstat -class).",
+			[]string{"pid"}, constLabels,
+		),
+		compilerTasks: prometheus.NewDesc(
+			prometheus.BuildFQName(*namespace, *subsystem, metricName("compilerTasks", "compiler_tasks_total")),
+			"Number of compilation tasks performed (Compiled of jstat -compiler).",
+			[]string{"pid"}, constLabels,
+		),
+		compilerFailed: prometheus.NewDesc(
+			prometheus.BuildFQName(*namespace, *subsystem, metricName("compilerFailed", "compiler_failed_tasks_total")),
+			"Number of compilation tasks that failed (Failed of jstat -compiler).",
+			[]string{"pid"}, constLabels,
+		),
+		compilerInvalid: prometheus.NewDesc(
+			prometheus.BuildFQName(*namespace, *subsystem, metricName("compilerInvalid", "compiler_invalidated_tasks_total")),
+			"Number of compilation tasks that were invalidated (Invalid of jstat -compiler).",
+			[]string{"pid"}, constLabels,
+		),
+		compilerSec: prometheus.NewDesc(
+			prometheus.BuildFQName(*namespace, *subsystem, metricName("compilerSec", "compiler_time_seconds_total")),
+			"Time spent performing compilation tasks in seconds (Time of jstat -compiler).",
+			[]string{"pid"}, constLabels,
+		),
+		compilerFailedMethod: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("compilerFailedMethod", "compiler_last_failed_method"),
+			Help:        "Always 1, labeled with the class and method of the last failed compilation (FailedMethod of jstat -compiler).",
+			ConstLabels: constLabels,
+		}, []string{"pid", "method"}),
+		sv0CapacityMax: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("sv0CapacityMax", "survivor0_capacity_max_bytes"),
+			Help:        "Maximum survivor space 0 capacity in " + size + " (S0CMX of jstat -gcnewcapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		sv1CapacityMax: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("sv1CapacityMax", "survivor1_capacity_max_bytes"),
+			Help:        "Maximum survivor space 1 capacity in " + size + " (S1CMX of jstat -gcnewcapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		edenCapacityMax: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("edenCapacityMax", "eden_capacity_max_bytes"),
+			Help:        "Maximum eden space capacity in " + size + " (ECMX of jstat -gcnewcapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		oldCapacityMin: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("oldCapacityMin", "gcoldcapacity_old_gen_capacity_min_bytes"),
+			Help:        "Minimum old generation capacity in " + size + " (OGCMN of jstat -gcoldcapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		oldCapacityMax: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("oldCapacityMax", "gcoldcapacity_old_gen_capacity_max_bytes"),
+			Help:        "Maximum old generation capacity in " + size + " (OGCMX of jstat -gcoldcapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		oldCapacityCurrent: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("oldCapacityCurrent", "gcoldcapacity_old_gen_capacity_bytes"),
+			Help:        "Current old generation capacity in " + size + " (OGC of jstat -gcoldcapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		oldCapacityCommitted: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("oldCapacityCommitted", "gcoldcapacity_old_capacity_bytes"),
+			Help:        "Current old space capacity in " + size + " (OC of jstat -gcoldcapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		metaCapacityMin: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("metaCapacityMin", "gcmetacapacity_metaspace_capacity_min_bytes"),
+			Help:        "Minimum metaspace capacity in " + size + " (MCMN of jstat -gcmetacapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		metaCapacityMax: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("metaCapacityMax", "gcmetacapacity_metaspace_capacity_max_bytes"),
+			Help:        "Maximum metaspace capacity in " + size + " (MCMX of jstat -gcmetacapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		metaCapacityCurrent: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("metaCapacityCurrent", "gcmetacapacity_metaspace_capacity_bytes"),
+			Help:        "Metaspace capacity in " + size + " (MC of jstat -gcmetacapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		ccsCapacityMin: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("ccsCapacityMin", "gcmetacapacity_ccs_capacity_min_bytes"),
+			Help:        "Minimum compressed class space capacity in " + size + " (CCSMN of jstat -gcmetacapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		ccsCapacityMax: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("ccsCapacityMax", "gcmetacapacity_ccs_capacity_max_bytes"),
+			Help:        "Maximum compressed class space capacity in " + size + " (CCSMX of jstat -gcmetacapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		ccsCapacityCurrent: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        metricName("ccsCapacityCurrent", "gcmetacapacity_ccs_capacity_bytes"),
+			Help:        "Compressed class space capacity in " + size + " (CCSC of jstat -gcmetacapacity).",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
+		up: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        "up",
+			Help:        "1 when the target JVM was found and jstat returned data for it on the last scrape, 0 otherwise.",
+			ConstLabels: constLabels,
 		}),
-		sv0Used: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "sv0Used",
-			Help:      "sv0Used",
+		monitoredJvms: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        "monitored_jvms",
+			Help:        "Number of JVMs jstat returned data for on the last scrape.",
+			ConstLabels: constLabels,
 		}),
-		sv1Used: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "sv1Used",
-			Help:      "sv1Used",
+		targetPidGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        "target_pid",
+			Help:        "Pid the target name or pidfile resolved to on the last scrape, 0 when it did not resolve. The first one with -target.all.",
+			ConstLabels: constLabels,
 		}),
-		edenUsed: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "edenUsed",
-			Help:      "edenUsed",
+		lastScrapeTimestamp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        "last_scrape_timestamp_seconds",
+			Help:        "Unix time in seconds of the last successful jstat run, by pid and command.",
+			ConstLabels: constLabels,
+		}, []string{"pid", "command"}),
+		sampleInterval: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        "sample_interval_seconds",
+			Help:        "Minimum time in seconds between runs of each jstat command, from -interval.<mode> or the target's interval, 0 when it runs on every scrape.",
+			ConstLabels: constLabels,
+		}, []string{"command"}),
+		commandInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        "command_info",
+			Help:        "Always 1, labeled with the jstat path and the arguments each command runs with before the vmid.",
+			ConstLabels: constLabels,
+		}, []string{"command", "args", "jstat_path"}),
+		parseErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        "parse_errors_total",
+			Help:        "Number of jstat values that could not be parsed.",
+			ConstLabels: constLabels,
 		}),
-		fgcTimes: prometheus.NewCounter(prometheus.CounterOpts{
-			Namespace: namespace,
-			Name:      "fgcTimes",
-			Help:      "fgcTimes",
+		commandErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        "command_errors_total",
+			Help:        "Number of jstat runs that failed or returned no sample, by command.",
+			ConstLabels: constLabels,
+		}, []string{"command"}),
+		scrapeDuration: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        "exporter_scrape_duration_seconds",
+			Help:        "Time in seconds the last scrape took, including all jps and jstat runs.",
+			ConstLabels: constLabels,
 		}),
-		fgcSec: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "fgcSec",
-			Help:      "fgcSec",
+		scrapeErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        "exporter_scrape_errors_total",
+			Help:        "Number of jstat commands that failed, and values that were missing or did not parse, during scrapes.",
+			ConstLabels: constLabels,
 		}),
+		jvmUptime: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace:   *namespace,
+			Subsystem:   *subsystem,
+			Name:        "jvm_uptime_seconds",
+			Help:        "Seconds since the target JVM started, from the jstat -t Timestamp column.",
+			ConstLabels: constLabels,
+		}, []string{"pid"}),
 	}
+	for _, mode := range jstatModes {
+		if e.enabled(mode.name) {
+			e.sampleInterval.WithLabelValues(mode.name).Set(e.modeInterval(mode.name).Seconds())
+			e.commandInfo.WithLabelValues(mode.name, strings.Join(e.jstatArgs("-"+mode.name), " "), e.jstatPath).Set(1)
+		}
+	}
+	for _, option := range e.options {
+		e.commandInfo.WithLabelValues(option, strings.Join(e.jstatArgs("-"+option), " "), e.jstatPath).Set(1)
+	}
+	return e
+}
+
+// Stop kills any jstat or jps command still running for a scrape and waits
+// for it to exit. Commands started after Stop fail immediately.
+func (e *Exporter) Stop() {
+	e.cancel()
+	e.running.Lock()
+	e.running.Unlock()
 }
 
 // Describe implements the prometheus.Collector interface.
 func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
+	e.newMin.Describe(ch)
 	e.newMax.Describe(ch)
 	e.newCommit.Describe(ch)
+	e.oldMin.Describe(ch)
 	e.oldMax.Describe(ch)
 	e.oldCommit.Describe(ch)
+	e.oldCurrent.Describe(ch)
 	e.metaMax.Describe(ch)
 	e.metaCommit.Describe(ch)
+	e.metaMin.Describe(ch)
+	e.ccsMin.Describe(ch)
+	e.ccsMax.Describe(ch)
+	e.ccsCurrent.Describe(ch)
 	e.metaUsed.Describe(ch)
 	e.oldUsed.Describe(ch)
+	e.permGenCapacity.Describe(ch)
+	e.permGenUsed.Describe(ch)
+	e.permGenMin.Describe(ch)
+	e.permGenMax.Describe(ch)
+	e.permGenCommit.Describe(ch)
 	e.sv0Used.Describe(ch)
 	e.sv1Used.Describe(ch)
 	e.edenUsed.Describe(ch)
-	e.fgcTimes.Describe(ch)
-	e.fgcSec.Describe(ch)
+	e.gcnewSv0Capacity.Describe(ch)
+	e.gcnewSv1Capacity.Describe(ch)
+	e.edenUtilization.Describe(ch)
+	e.survivorUtilization.Describe(ch)
+	e.oldUtilization.Describe(ch)
+	e.tenuringThreshold.Describe(ch)
+	e.maxTenuringThreshold.Describe(ch)
+	e.desiredSurvivorSize.Describe(ch)
+	e.sv0Capacity.Describe(ch)
+	e.sv1Capacity.Describe(ch)
+	e.edenCapacity.Describe(ch)
+	e.oldCapacity.Describe(ch)
+	e.ccsCapacity.Describe(ch)
+	e.ccsUsed.Describe(ch)
+	ch <- e.ygcTimes
+	ch <- e.ygcSec
+	ch <- e.fgcTimes
+	ch <- e.fgcSec
+	ch <- e.cgcTimes
+	ch <- e.cgcSec
+	ch <- e.gcTotalSec
+	e.gcOverhead.Describe(ch)
+	e.heapUsed.Describe(ch)
+	e.heapCommitted.Describe(ch)
+	e.heapUtilization.Describe(ch)
+	e.metaUtilization.Describe(ch)
+	e.survivor0Pct.Describe(ch)
+	e.survivor1Pct.Describe(ch)
+	e.edenPct.Describe(ch)
+	e.oldPct.Describe(ch)
+	e.metaPct.Describe(ch)
+	e.ccsPct.Describe(ch)
+	e.lastGcCause.Describe(ch)
+	e.currentGcCause.Describe(ch)
+	ch <- e.classesLoaded
+	ch <- e.classBytesLoaded
+	ch <- e.classesUnloaded
+	ch <- e.classBytesUnloaded
+	ch <- e.classLoadSec
+	ch <- e.compilerTasks
+	ch <- e.compilerFailed
+	ch <- e.compilerInvalid
+	ch <- e.compilerSec
+	e.compilerFailedMethod.Describe(ch)
+	e.sv0CapacityMax.Describe(ch)
+	e.sv1CapacityMax.Describe(ch)
+	e.edenCapacityMax.Describe(ch)
+	e.oldCapacityMin.Describe(ch)
+	e.oldCapacityMax.Describe(ch)
+	e.oldCapacityCurrent.Describe(ch)
+	e.oldCapacityCommitted.Describe(ch)
+	e.metaCapacityMin.Describe(ch)
+	e.metaCapacityMax.Describe(ch)
+	e.metaCapacityCurrent.Describe(ch)
+	e.ccsCapacityMin.Describe(ch)
+	e.ccsCapacityMax.Describe(ch)
+	e.ccsCapacityCurrent.Describe(ch)
+	e.up.Describe(ch)
+	e.monitoredJvms.Describe(ch)
+	e.targetPidGauge.Describe(ch)
+	e.lastScrapeTimestamp.Describe(ch)
+	e.sampleInterval.Describe(ch)
+	e.commandInfo.Describe(ch)
+	e.parseErrors.Describe(ch)
+	e.commandErrors.Describe(ch)
+	e.scrapeDuration.Describe(ch)
+	e.scrapeErrors.Describe(ch)
+	e.jvmUptime.Describe(ch)
 }
 
 // Collect implements the prometheus.Collector interface.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
-	e.JstatGccapacity(ch)
-	e.JstatGcold(ch)
-	e.JstatGcnew(ch)
-	e.JstatGc(ch)
-}
-
-func (e *Exporter) JstatGccapacity(ch chan<- prometheus.Metric) {
-
-	out, err := exec.Command(e.jstatPath, "-gccapacity", e.targetPid).Output()
-	if err != nil {
-		log.Fatal(err)
+	e.running.RLock()
+	defer e.running.RUnlock()
+	start := time.Now()
+	pids := e.pids()
+	up := 0.0
+	if len(pids) > 0 {
+		up = 1
 	}
-
-	for i, line := range strings.Split(string(out), "\n") {
-		if i == 1 {
-			parts := strings.Fields(line)
-			newMax, err := strconv.ParseFloat(parts[1], 64)
-			if err != nil {
-				log.Fatal(err)
+	monitored := 0
+	for _, pid := range pids {
+		// Rather than running every jstat command against a given pid
+		// that has exited, skip it until it is running again.
+		if e.target == "" && e.remote == "" && processExited(pid) {
+			slog.Error("Target pid is not running", "pid", pid)
+			up = 0
+			continue
+		}
+		collected := make(map[string]bool)
+		for _, mode := range jstatModes {
+			if !e.enabled(mode.name) {
+				continue
 			}
-			e.newMax.Set(newMax)
-			e.newMax.Collect(ch)
-			newCommit, err := strconv.ParseFloat(parts[2], 64)
-			if err != nil {
-				log.Fatal(err)
+			err := mode.collect(e, ch, pid)
+			if err == errUnavailable {
+				continue
 			}
-			e.newCommit.Set(newCommit)
-			e.newCommit.Collect(ch)
-			oldMax, err := strconv.ParseFloat(parts[7], 64)
 			if err != nil {
-				log.Fatal(err)
+				slog.Error("Failed to collect", "pid", pid, "err", err)
+				e.scrapeErrors.Inc()
+				continue
 			}
-			e.oldMax.Set(oldMax)
-			e.oldMax.Collect(ch)
-			oldCommit, err := strconv.ParseFloat(parts[8], 64)
-			if err != nil {
-				log.Fatal(err)
+			collected[mode.name] = true
+			if e.extraColumns {
+				e.collectExtraColumns(ch, pid, mode.name)
+			}
+		}
+		for _, option := range e.options {
+			_, _, err := e.jstat("-"+option, pid)
+			if err == errUnavailable {
+				continue
 			}
-			e.oldCommit.Set(oldCommit)
-			e.oldCommit.Collect(ch)
-			metaMax, err := strconv.ParseFloat(parts[11], 64)
 			if err != nil {
-				log.Fatal(err)
+				slog.Error("Failed to collect", "pid", pid, "err", err)
+				e.scrapeErrors.Inc()
+				continue
 			}
-			e.metaMax.Set(metaMax)
-			e.metaMax.Collect(ch)
-			metaCommit, err := strconv.ParseFloat(parts[12], 64)
+			collected[option] = true
+			e.collectExtraColumns(ch, pid, option)
+		}
+		if e.heapInfo {
+			ok, err := e.collectHeapInfo(ch, pid, collected)
 			if err != nil {
-				log.Fatal(err)
+				slog.Error("Failed to collect", "pid", pid, "err", err)
+				e.scrapeErrors.Inc()
+			} else if ok {
+				collected["heap_info"] = true
 			}
-			e.metaCommit.Set(metaCommit)
-			e.metaCommit.Collect(ch)
 		}
+		if len(collected) == 0 {
+			up = 0
+			continue
+		}
+		monitored++
+		if e.timestamp {
+			e.jvmUptime.WithLabelValues(pid).Collect(ch)
+		}
+		if collected["gc"] {
+			e.collectGCOverhead(ch, pid)
+		}
+		if collected["gc"] && collected["gccapacity"] {
+			e.collectHeapUtilization(ch, pid)
+		}
+		if collected["gcold"] && collected["gccapacity"] {
+			e.collectMetaUtilization(ch, pid)
+		}
+	}
+	e.up.Set(up)
+	e.up.Collect(ch)
+	e.mu.Lock()
+	e.lastUp, e.lastCollect = up == 1, time.Now()
+	e.mu.Unlock()
+	e.monitoredJvms.Set(float64(monitored))
+	e.monitoredJvms.Collect(ch)
+	if e.target != "" || e.pidFile != "" {
+		targetPid := 0.0
+		if len(pids) > 0 {
+			targetPid, _ = strconv.ParseFloat(pids[0], 64)
+		}
+		e.targetPidGauge.Set(targetPid)
+		e.targetPidGauge.Collect(ch)
 	}
+	e.collectLastScrape(ch, pids)
+	e.sampleInterval.Collect(ch)
+	e.commandInfo.Collect(ch)
+	e.parseErrors.Collect(ch)
+	e.commandErrors.Collect(ch)
+	e.scrapeErrors.Collect(ch)
+	e.scrapeDuration.Set(time.Since(start).Seconds())
+	e.scrapeDuration.Collect(ch)
 }
 
-func (e *Exporter) JstatGcold(ch chan<- prometheus.Metric) {
+// collectHeapUtilization collects the used share of the maximum heap. Used
+// comes from the last -gc sample and the maximum (NGCMX+OGCMX, or OGCMX alone
+// under G1) from the last -gccapacity sample, so both must have been collected for pid in this scrape.
+func (e *Exporter) collectHeapUtilization(ch chan<- prometheus.Metric, pid string) {
+	e.mu.Lock()
+	gc := e.lastScrape[jstatRun{pid, "-gc"}]
+	capacity := e.lastScrape[jstatRun{pid, "-gccapacity"}]
+	e.mu.Unlock()
+	used, ok := sumColumns(parseSample(gc.header, gc.line), "S0U", "S1U", "EU", "OU")
+	if !ok {
+		return
+	}
+	sample := parseSample(capacity.header, capacity.line)
+	youngMax, ok := sumColumns(sample, "NGCMX")
+	if !ok {
+		return
+	}
+	oldMax, ok := sumColumns(sample, "OGCMX")
+	if !ok {
+		return
+	}
+	// G1 sizes its generations from one pool of regions and reports the
+	// whole maximum heap as the maximum of both.
+	heapMax := youngMax + oldMax
+	if youngMax == oldMax {
+		heapMax = oldMax
+	}
+	if heapMax <= 0 {
+		return
+	}
+	heapUtilization := e.heapUtilization.WithLabelValues(pid)
+	heapUtilization.Set(used / heapMax)
+	heapUtilization.Collect(ch)
+}
 
-	out, err := exec.Command(e.jstatPath, "-gcold", e.targetPid).Output()
-	if err != nil {
-		log.Fatal(err)
+// collectGCOverhead collects the share of wall-clock time the JVM spent in GC
+// between the last two -gc samples, from the growth of their GCT column. A
+// sample reused within -interval.gc keeps the previous value. Nothing is
+// collected until there are two samples or after GCT went down, which means
+// the pid now belongs to a new JVM.
+func (e *Exporter) collectGCOverhead(ch chan<- prometheus.Metric, pid string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	gc := e.lastScrape[jstatRun{pid, "-gc"}]
+	seconds, ok := sumColumns(parseSample(gc.header, gc.line), "GCT")
+	if !ok {
+		return
+	}
+	last, ok := e.gcTimes[pid]
+	if !ok || gc.time.After(last.time) {
+		overhead := -1.0
+		if ok && seconds >= last.seconds {
+			overhead = (seconds - last.seconds) / gc.time.Sub(last.time).Seconds()
+		}
+		last = gcTime{gc.time, seconds, overhead}
+		e.gcTimes[pid] = last
 	}
+	if last.overhead < 0 {
+		return
+	}
+	gcOverhead := e.gcOverhead.WithLabelValues(pid)
+	gcOverhead.Set(last.overhead)
+	gcOverhead.Collect(ch)
+}
 
-	for i, line := range strings.Split(string(out), "\n") {
-		if i == 1 {
-			parts := strings.Fields(line)
-			metaUsed, err := strconv.ParseFloat(parts[1], 64)
-			if err != nil {
-				log.Fatal(err)
-			}
-			e.metaUsed.Set(metaUsed) // MU: Metaspace utilization (kB).
-			e.metaUsed.Collect(ch)
-			oldUsed, err := strconv.ParseFloat(parts[5], 64)
-			if err != nil {
-				log.Fatal(err)
-			}
-			e.oldUsed.Set(oldUsed) // OU: Old space utilization (kB).
-			e.oldUsed.Collect(ch)
+// collectMetaUtilization collects the used share of the maximum metaspace,
+// MU from the last -gcold sample over MCMX from the last -gccapacity sample.
+// Both must have been collected for pid in this scrape.
+func (e *Exporter) collectMetaUtilization(ch chan<- prometheus.Metric, pid string) {
+	e.mu.Lock()
+	gcold := e.lastScrape[jstatRun{pid, "-gcold"}]
+	capacity := e.lastScrape[jstatRun{pid, "-gccapacity"}]
+	e.mu.Unlock()
+	used, ok := sumColumns(parseSample(gcold.header, gcold.line), "MU")
+	if !ok {
+		return
+	}
+	metaMax, ok := sumColumns(parseSample(capacity.header, capacity.line), "MCMX")
+	if !ok || metaMax <= 0 {
+		return
+	}
+	metaUtilization := e.metaUtilization.WithLabelValues(pid)
+	metaUtilization.Set(used / metaMax)
+	metaUtilization.Collect(ch)
+}
+
+// collectExtraColumns collects every numeric column of the last sample of a
+// mode as jstat_<mode>_<column>, with the lower-cased column name and the
+// value exactly as jstat printed it. This covers columns that only some
+// collectors or JDK versions print, which have no metric of their own.
+func (e *Exporter) collectExtraColumns(ch chan<- prometheus.Metric, pid string, mode string) {
+	e.mu.Lock()
+	result := e.lastScrape[jstatRun{pid, "-" + mode}]
+	e.mu.Unlock()
+	for column, field := range parseSample(result.header, result.line) {
+		value, err := parseValue(field)
+		if err != nil {
+			continue
 		}
+		name := strings.ToLower(invalidNameChars.ReplaceAllString(column, "_"))
+		desc := prometheus.NewDesc(
+			prometheus.BuildFQName(*namespace, *subsystem, mode+"_"+name),
+			fmt.Sprintf("Column %s of jstat -%s, in the unit jstat prints it in.", column, mode),
+			[]string{"pid"}, e.constLabels,
+		)
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value, pid)
 	}
 }
 
-func (e *Exporter) JstatGcnew(ch chan<- prometheus.Metric) {
+// collectLastScrape collects the time of the last successful run of each jstat
+// command for the given pids.
+func (e *Exporter) collectLastScrape(ch chan<- prometheus.Metric, pids []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, pid := range pids {
+		for run, result := range e.lastScrape {
+			if run.pid != pid {
+				continue
+			}
+			lastScrape := e.lastScrapeTimestamp.WithLabelValues(pid, strings.TrimPrefix(run.option, "-"))
+			lastScrape.Set(float64(result.time.UnixNano()) / 1e9)
+			lastScrape.Collect(ch)
+		}
+	}
+}
 
-	out, err := exec.Command(e.jstatPath, "-gcnew", e.targetPid).Output()
-	if err != nil {
-		log.Fatal(err)
+// enabled reports whether the named jstat mode should be collected. Modes
+// turned off with -collect.<mode> never are; the others are all collected
+// when none were configured.
+func (e *Exporter) enabled(mode string) bool {
+	if !*collectModes[mode] {
+		return false
+	}
+	if len(e.modes) == 0 {
+		return true
+	}
+	for _, m := range e.modes {
+		if m == mode {
+			return true
+		}
 	}
+	return false
+}
 
-	for i, line := range strings.Split(string(out), "\n") {
-		if i == 1 {
-			parts := strings.Fields(line)
-			sv0Used, err := strconv.ParseFloat(parts[2], 64)
-			if err != nil {
-				log.Fatal(err)
+// Healthy reports whether jstat_up was 1 on the last scrape. Without a
+// scrape within -web.health-max-age, such as right after the exporter
+// started, it collects the target first. Pids given on the command line
+// must also still be running, since one may have exited since that scrape.
+func (e *Exporter) Healthy() bool {
+	e.mu.Lock()
+	up, collected := e.lastUp, e.lastCollect
+	e.mu.Unlock()
+	if time.Since(collected) > *healthMaxAge {
+		ch := make(chan prometheus.Metric)
+		go func() {
+			e.Collect(ch)
+			close(ch)
+		}()
+		for range ch {
+		}
+		e.mu.Lock()
+		up = e.lastUp
+		e.mu.Unlock()
+	}
+	if !up {
+		return false
+	}
+	if e.target == "" && e.pidFile == "" && e.remote == "" {
+		for _, pid := range e.targetPids {
+			if processExited(pid) {
+				return false
 			}
-			e.sv0Used.Set(sv0Used)
-			e.sv0Used.Collect(ch)
-			sv1Used, err := strconv.ParseFloat(parts[3], 64)
-			if err != nil {
-				log.Fatal(err)
+		}
+	}
+	return true
+}
+
+// WriteDebug writes the resolved pids and the raw output of the last
+// successful run of each jstat command as plain text.
+func (e *Exporter) WriteDebug(w io.Writer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	switch {
+	case e.target != "":
+		fmt.Fprintf(w, "target: %s\npids: %s\n", e.target, strings.Join(e.resolved, ","))
+	case e.pidFile != "":
+		fmt.Fprintf(w, "pidfile: %s\npids: %s\n", e.pidFile, strings.Join(e.resolved, ","))
+	default:
+		fmt.Fprintf(w, "pids: %s\n", strings.Join(e.targetPids, ","))
+	}
+	runs := make([]jstatRun, 0, len(e.lastScrape))
+	for run := range e.lastScrape {
+		runs = append(runs, run)
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		if runs[i].pid != runs[j].pid {
+			return runs[i].pid < runs[j].pid
+		}
+		return runs[i].option < runs[j].option
+	})
+	for _, run := range runs {
+		result := e.lastScrape[run]
+		fmt.Fprintf(w, "\njstat %s %s at %s\n%s\n%s\n", run.option, run.pid, result.time.Format(time.RFC3339), result.header, result.line)
+	}
+}
+
+// pids returns the pids to collect, resolving the target with jps when the
+// exporter was given a main class name instead of pids, or reading them from
+// the pidfile.
+func (e *Exporter) pids() []string {
+	if e.target == "" && e.pidFile == "" {
+		return e.targetPids
+	}
+	var pids []string
+	name := e.target
+	if e.target == "" {
+		name = e.pidFile
+		// The pidfile is read on every scrape, so a restarted JVM is
+		// picked up as soon as its launcher writes the new pid.
+		pid, err := readPidFile(e.pidFile)
+		if err != nil {
+			slog.Error("Failed to resolve target", "pidfile", e.pidFile, "err", err)
+			return nil
+		}
+		pids = []string{pid}
+	} else {
+		ctx, cancel := context.WithTimeout(e.ctx, e.jpsTimeout)
+		defer cancel()
+		var err error
+		pids, err = Jps(ctx, e.discovery, e.jpsPath, e.remote, e.target, e.matchArgs)
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("%s timed out after %s", e.discovery, e.jpsTimeout)
+		}
+		if err != nil {
+			slog.Error("Failed to resolve target", "target", e.target, "err", err)
+			return nil
+		}
+		if e.user != "" && e.remote == "" {
+			pids = ownedBy(ctx, pids, e.user)
+			if len(pids) == 0 {
+				slog.Error("Failed to resolve target: no JVM owned by user", "target", e.target, "user", e.user)
+				return nil
 			}
-			e.sv1Used.Set(sv1Used)
-			e.sv1Used.Collect(ch)
-			edenUsed, err := strconv.ParseFloat(parts[8], 64)
-			if err != nil {
-				log.Fatal(err)
+		}
+		if len(pids) > 1 && !e.allMatches {
+			slog.Warn("Several JVMs match target, only monitoring the first", "target", e.target, "pid", pids[0], "matches", strings.Join(pids, ","))
+			pids = pids[:1]
+		}
+	}
+	e.mu.Lock()
+	if strings.Join(pids, ",") != strings.Join(e.resolved, ",") {
+		if len(e.resolved) > 0 {
+			slog.Info("Target moved to another pid", "target", name, "from", strings.Join(e.resolved, ","), "to", strings.Join(pids, ","))
+		}
+		for _, pid := range e.resolved {
+			if !contains(pids, pid) {
+				e.forget(pid)
 			}
-			e.edenUsed.Set(edenUsed)
-			e.edenUsed.Collect(ch)
 		}
+		e.resolved = pids
 	}
+	e.mu.Unlock()
+	return pids
 }
 
-func (e *Exporter) JstatGc(ch chan<- prometheus.Metric) {
+// contains reports whether s is one of list.
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
 
-	out, err := exec.Command(e.jstatPath, "-gc", e.targetPid).Output()
+// forget drops what is remembered about a pid that is no longer the target,
+// so the series of a restarted JVM do not linger. The new JVM is exported
+// under its own pid label, which starts its counters from zero. e.mu must be
+// held.
+func (e *Exporter) forget(pid string) {
+	for run := range e.lastScrape {
+		if run.pid == pid {
+			delete(e.lastScrape, run)
+		}
+	}
+	delete(e.gcTimes, pid)
+	e.jvmUptime.DeleteLabelValues(pid)
+}
+
+func (e *Exporter) JstatGccapacity(ch chan<- prometheus.Metric, pid string) error {
+	header, line, err := e.jstat("-gccapacity", pid)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	for i, line := range strings.Split(string(out), "\n") {
-		if i == 1 {
-			parts := strings.Fields(line)
-			fgcTimes, err := strconv.ParseFloat(parts[14], 64)
-			if err != nil {
-				log.Fatal(err)
-			}
-			e.fgcTimes.Set(fgcTimes)
-			e.fgcTimes.Collect(ch)
-			fgcSec, err := strconv.ParseFloat(parts[15], 64)
-			if err != nil {
-				log.Fatal(err)
-			}
-			e.fgcSec.Set(fgcSec)
-			e.fgcSec.Collect(ch)
+	sample := parseSample(header, line)
+	e.collectColumn(ch, e.newMin.WithLabelValues(pid), sample, "NGCMN")  // NGCMN: Minimum new generation capacity (kB).
+	e.collectColumn(ch, e.newMax.WithLabelValues(pid), sample, "NGCMX")  // NGCMX: Maximum new generation capacity (kB).
+	e.collectColumn(ch, e.newCommit.WithLabelValues(pid), sample, "NGC") // NGC: Current new generation capacity (kB).
+	e.collectOldCapacity(ch, sample, e.oldMin.WithLabelValues(pid), e.oldMax.WithLabelValues(pid), e.oldCommit.WithLabelValues(pid), e.oldCurrent.WithLabelValues(pid))
+	e.collectMetaCapacity(ch, sample, e.metaMin.WithLabelValues(pid), e.metaMax.WithLabelValues(pid), e.metaCommit.WithLabelValues(pid), e.ccsMin.WithLabelValues(pid), e.ccsMax.WithLabelValues(pid), e.ccsCurrent.WithLabelValues(pid))
+	// JDK 7 has a permanent generation instead of metaspace. Its PC is
+	// collected from -gcold along with PU.
+	e.collectColumn(ch, e.permGenMin.WithLabelValues(pid), sample, "PGCMN")  // PGCMN: Minimum permanent generation capacity (kB).
+	e.collectColumn(ch, e.permGenMax.WithLabelValues(pid), sample, "PGCMX")  // PGCMX: Maximum permanent generation capacity (kB).
+	e.collectColumn(ch, e.permGenCommit.WithLabelValues(pid), sample, "PGC") // PGC: Current permanent generation capacity (kB).
+	return nil
+}
+
+func (e *Exporter) JstatGcold(ch chan<- prometheus.Metric, pid string) error {
+	header, line, err := e.jstat("-gcold", pid)
+	if err != nil {
+		return err
+	}
+
+	sample := parseSample(header, line)
+	e.collectColumn(ch, e.metaUsed.WithLabelValues(pid), sample, "MU") // MU: Metaspace utilization (kB).
+	e.collectColumn(ch, e.oldUsed.WithLabelValues(pid), sample, "OU")  // OU: Old space utilization (kB).
+	// JDK 7 has a permanent generation instead of metaspace.
+	e.collectColumn(ch, e.permGenCapacity.WithLabelValues(pid), sample, "PC") // PC: Current permanent space capacity (kB).
+	e.collectColumn(ch, e.permGenUsed.WithLabelValues(pid), sample, "PU")     // PU: Permanent space utilization (kB).
+	e.collectRatio(ch, e.oldUtilization.WithLabelValues(pid), sample, []string{"OU"}, []string{"OC"})
+	return nil
+}
+
+func (e *Exporter) JstatGcnew(ch chan<- prometheus.Metric, pid string) error {
+	header, line, err := e.jstat("-gcnew", pid)
+	if err != nil {
+		return err
+	}
+
+	sample := parseSample(header, line)
+	e.collectColumn(ch, e.sv0Used.WithLabelValues(pid), sample, "S0U")              // S0U: Survivor space 0 utilization (kB).
+	e.collectColumn(ch, e.sv1Used.WithLabelValues(pid), sample, "S1U")              // S1U: Survivor space 1 utilization (kB).
+	e.collectColumn(ch, e.tenuringThreshold.WithLabelValues(pid), sample, "TT")     // TT: Tenuring threshold.
+	e.collectColumn(ch, e.maxTenuringThreshold.WithLabelValues(pid), sample, "MTT") // MTT: Maximum tenuring threshold.
+	e.collectColumn(ch, e.desiredSurvivorSize.WithLabelValues(pid), sample, "DSS")  // DSS: Desired survivor size (kB).
+	e.collectColumn(ch, e.edenUsed.WithLabelValues(pid), sample, "EU")              // EU: Eden space utilization (kB).
+	e.collectColumn(ch, e.gcnewSv0Capacity.WithLabelValues(pid), sample, "S0C")     // S0C: Current survivor space 0 capacity (kB).
+	e.collectColumn(ch, e.gcnewSv1Capacity.WithLabelValues(pid), sample, "S1C")     // S1C: Current survivor space 1 capacity (kB).
+	e.collectRatio(ch, e.edenUtilization.WithLabelValues(pid), sample, []string{"EU"}, []string{"EC"})
+	e.collectRatio(ch, e.survivorUtilization.WithLabelValues(pid), sample, []string{"S0U", "S1U"}, []string{"S0C", "S1C"})
+	return nil
+}
+
+// JstatGc collects jstat -gc. The GC counts and times are running totals of
+// the JVM and are exported as counters. A restarted JVM shows up under its new
+// pid; if a pid is reused, the totals fall back to zero, which rate() already
+// treats as a counter reset. The GC event counts carry an exemplar stamped
+// with the time of the sample.
+func (e *Exporter) JstatGc(ch chan<- prometheus.Metric, pid string) error {
+	header, line, err := e.jstat("-gc", pid)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	sampled := e.lastScrape[jstatRun{pid, "-gc"}].time
+	e.mu.Unlock()
+
+	sample := parseSample(header, line)
+	e.collectColumn(ch, e.sv0Capacity.WithLabelValues(pid), sample, "S0C")        // S0C: Current survivor space 0 capacity (kB).
+	e.collectColumn(ch, e.sv1Capacity.WithLabelValues(pid), sample, "S1C")        // S1C: Current survivor space 1 capacity (kB).
+	e.collectColumn(ch, e.edenCapacity.WithLabelValues(pid), sample, "EC")        // EC: Current eden space capacity (kB).
+	e.collectColumn(ch, e.oldCapacity.WithLabelValues(pid), sample, "OC")         // OC: Current old space capacity (kB).
+	e.collectColumn(ch, exemplarCounter(e.ygcTimes, pid, sampled), sample, "YGC") // YGC: Number of young generation GC events.
+	e.collectColumn(ch, counter(e.ygcSec, pid), sample, "YGCT")                   // YGCT: Young generation garbage collection time.
+	e.collectColumn(ch, exemplarCounter(e.fgcTimes, pid, sampled), sample, "FGC") // FGC: Number of full GC events.
+	e.collectColumn(ch, counter(e.fgcSec, pid), sample, "FGCT")                   // FGCT: Full garbage collection time.
+	e.collectColumn(ch, exemplarCounter(e.cgcTimes, pid, sampled), sample, "CGC") // CGC: Number of concurrent GC events (JDK 11+).
+	e.collectColumn(ch, counter(e.cgcSec, pid), sample, "CGCT")                   // CGCT: Concurrent garbage collection time (JDK 11+).
+	// CCSC/CCSU are only printed when compressed class pointers are in use.
+	e.collectColumn(ch, e.ccsCapacity.WithLabelValues(pid), sample, "CCSC") // CCSC: Compressed class space capacity (kB).
+	e.collectColumn(ch, e.ccsUsed.WithLabelValues(pid), sample, "CCSU")     // CCSU: Compressed class space used (kB).
+	e.collectColumn(ch, counter(e.gcTotalSec, pid), sample, "GCT")          // GCT: Total garbage collection time.
+	e.collectSum(ch, e.heapUsed.WithLabelValues(pid), sample, "S0U", "S1U", "EU", "OU")
+	e.collectSum(ch, e.heapCommitted.WithLabelValues(pid), sample, "S0C", "S1C", "EC", "OC")
+	return nil
+}
+
+// JstatGcutil collects the jstat -gcutil columns. Unlike the other modes,
+// these values are percentages (0-100) rather than kB.
+func (e *Exporter) JstatGcutil(ch chan<- prometheus.Metric, pid string) error {
+	header, line, err := e.jstat("-gcutil", pid)
+	if err != nil {
+		return err
+	}
+
+	sample := parseSample(header, line)
+	e.collectColumn(ch, e.survivor0Pct.WithLabelValues(pid), sample, "S0") // S0: Survivor space 0 utilization as a percentage of the space's current capacity.
+	e.collectColumn(ch, e.survivor1Pct.WithLabelValues(pid), sample, "S1") // S1: Survivor space 1 utilization as a percentage of the space's current capacity.
+	e.collectColumn(ch, e.edenPct.WithLabelValues(pid), sample, "E")       // E: Eden space utilization as a percentage of the space's current capacity.
+	e.collectColumn(ch, e.oldPct.WithLabelValues(pid), sample, "O")        // O: Old space utilization as a percentage of the space's current capacity.
+	e.collectColumn(ch, e.metaPct.WithLabelValues(pid), sample, "M")       // M: Metaspace utilization as a percentage of the space's current capacity.
+	e.collectColumn(ch, e.ccsPct.WithLabelValues(pid), sample, "CCS")      // CCS: Compressed class space utilization as a percentage.
+	return nil
+}
+
+// JstatGccause collects the LGCC and GCC columns of jstat -gccause. The
+// causes are free text such as "Allocation Failure" and wider than their
+// columns when long, so they are found after the numeric columns instead of at
+// the header offsets.
+func (e *Exporter) JstatGccause(ch chan<- prometheus.Metric, pid string) error {
+	header, line, err := e.jstat("-gccause", pid)
+	if err != nil {
+		return err
+	}
+
+	lastCause, currentCause, err := splitGcCauses(header, line)
+	if err != nil {
+		return err
+	}
+	lastGcCause := e.lastGcCause.WithLabelValues(pid, lastCause)
+	lastGcCause.Set(1) // LGCC: Cause of last garbage collection.
+	lastGcCause.Collect(ch)
+	currentGcCause := e.currentGcCause.WithLabelValues(pid, currentCause)
+	currentGcCause.Set(1) // GCC: Cause of current garbage collection.
+	currentGcCause.Collect(ch)
+	return nil
+}
+
+// noGcCause is the GCC of jstat -gccause while no collection is running.
+const noGcCause = "No GC"
+
+// splitGcCauses returns the LGCC and GCC of a jstat -gccause line. Every
+// column but the last two is a number, so the causes are the text after as
+// many fields as the header has before them. The two causes are told apart by
+// a trailing "No GC", or else by the widest gap of spaces between them.
+func splitGcCauses(header, line string) (lastCause, currentCause string, err error) {
+	columns := strings.Fields(header)
+	numeric := len(columns) - 2
+	if numeric < 0 || columns[numeric] != "LGCC" || columns[numeric+1] != "GCC" {
+		return "", "", fmt.Errorf("unexpected jstat -gccause header: %q", header)
+	}
+	causes := strings.TrimSpace(line)
+	for i := 0; i < numeric; i++ {
+		end := strings.IndexAny(causes, " \t")
+		if end < 0 {
+			return "", "", fmt.Errorf("unexpected jstat -gccause output: %q", line)
+		}
+		causes = strings.TrimSpace(causes[end:])
+	}
+
+	if before, ok := strings.CutSuffix(causes, noGcCause); ok {
+		lastCause, currentCause = strings.TrimSpace(before), noGcCause
+	} else if gap := widestGap(causes); gap > 0 {
+		lastCause, currentCause = strings.TrimSpace(causes[:gap]), strings.TrimSpace(causes[gap:])
+	}
+	if lastCause == "" || currentCause == "" {
+		return "", "", fmt.Errorf("unexpected jstat -gccause output: %q", line)
+	}
+	return lastCause, currentCause, nil
+}
+
+// widestGap returns the offset of the longest run of two or more spaces in s,
+// or -1 if the words of s are all one space apart.
+func widestGap(s string) int {
+	offset, width := -1, 1
+	for i := 0; i < len(s); {
+		if s[i] != ' ' {
+			i++
+			continue
+		}
+		j := i
+		for j < len(s) && s[j] == ' ' {
+			j++
+		}
+		if j-i > width {
+			offset, width = i, j-i
+		}
+		i = j
+	}
+	return offset
+}
+
+// JstatClass collects the class loader statistics of jstat -class. The header
+// repeats "Bytes" for loaded and unloaded classes; parseSample names the second
+// one "Bytes2".
+func (e *Exporter) JstatClass(ch chan<- prometheus.Metric, pid string) error {
+	header, line, err := e.jstat("-class", pid)
+	if err != nil {
+		return err
+	}
+
+	sample := parseSample(header, line)
+	e.collectColumn(ch, counter(e.classesLoaded, pid), sample, "Loaded")      // Loaded: Number of classes loaded.
+	e.collectColumn(ch, counter(e.classBytesLoaded, pid), sample, "Bytes")    // Bytes: Number of kB loaded.
+	e.collectColumn(ch, counter(e.classesUnloaded, pid), sample, "Unloaded")  // Unloaded: Number of classes unloaded.
+	e.collectColumn(ch, counter(e.classBytesUnloaded, pid), sample, "Bytes2") // Bytes: Number of kB unloaded.
+	e.collectColumn(ch, counter(e.classLoadSec, pid), sample, "Time")         // Time: Time spent performing class loading and unloading operations.
+	return nil
+}
+
+// JstatCompiler collects the JIT compiler statistics of jstat -compiler.
+// FailedMethod is "class method" text and is empty (or "-") until a
+// compilation fails, so it is exported as a label only when present.
+func (e *Exporter) JstatCompiler(ch chan<- prometheus.Metric, pid string) error {
+	header, line, err := e.jstat("-compiler", pid)
+	if err != nil {
+		return err
+	}
+
+	sample := parseSample(header, line)
+	e.collectColumn(ch, counter(e.compilerTasks, pid), sample, "Compiled")  // Compiled: Number of compilation tasks performed.
+	e.collectColumn(ch, counter(e.compilerFailed, pid), sample, "Failed")   // Failed: Number of compilations tasks failed.
+	e.collectColumn(ch, counter(e.compilerInvalid, pid), sample, "Invalid") // Invalid: Number of compilation tasks that were invalidated.
+	e.collectColumn(ch, counter(e.compilerSec, pid), sample, "Time")        // Time: Time spent performing compilation tasks.
+	if failedMethod := sample["FailedMethod"]; failedMethod != "" && failedMethod != "-" {
+		compilerFailedMethod := e.compilerFailedMethod.WithLabelValues(pid, failedMethod)
+		compilerFailedMethod.Set(1) // FailedMethod: Class name and method of the last failed compilation.
+		compilerFailedMethod.Collect(ch)
+	}
+	return nil
+}
+
+// JstatGcnewcapacity collects the maximum survivor and eden capacities of
+// jstat -gcnewcapacity.
+func (e *Exporter) JstatGcnewcapacity(ch chan<- prometheus.Metric, pid string) error {
+	header, line, err := e.jstat("-gcnewcapacity", pid)
+	if err != nil {
+		return err
+	}
+
+	sample := parseSample(header, line)
+	e.collectColumn(ch, e.sv0CapacityMax.WithLabelValues(pid), sample, "S0CMX") // S0CMX: Maximum survivor space 0 capacity (kB).
+	e.collectColumn(ch, e.sv1CapacityMax.WithLabelValues(pid), sample, "S1CMX") // S1CMX: Maximum survivor space 1 capacity (kB).
+	e.collectColumn(ch, e.edenCapacityMax.WithLabelValues(pid), sample, "ECMX") // ECMX: Maximum eden space capacity (kB).
+	return nil
+}
+
+// JstatGcoldcapacity collects the old generation capacities of
+// jstat -gcoldcapacity.
+func (e *Exporter) JstatGcoldcapacity(ch chan<- prometheus.Metric, pid string) error {
+	header, line, err := e.jstat("-gcoldcapacity", pid)
+	if err != nil {
+		return err
+	}
+
+	sample := parseSample(header, line)
+	e.collectOldCapacity(ch, sample, e.oldCapacityMin.WithLabelValues(pid), e.oldCapacityMax.WithLabelValues(pid), e.oldCapacityCurrent.WithLabelValues(pid), e.oldCapacityCommitted.WithLabelValues(pid))
+	return nil
+}
+
+// JstatGcmetacapacity collects the metaspace and compressed class space
+// capacities of jstat -gcmetacapacity.
+func (e *Exporter) JstatGcmetacapacity(ch chan<- prometheus.Metric, pid string) error {
+	header, line, err := e.jstat("-gcmetacapacity", pid)
+	if err != nil {
+		return err
+	}
+
+	sample := parseSample(header, line)
+	e.collectMetaCapacity(ch, sample, e.metaCapacityMin.WithLabelValues(pid), e.metaCapacityMax.WithLabelValues(pid), e.metaCapacityCurrent.WithLabelValues(pid), e.ccsCapacityMin.WithLabelValues(pid), e.ccsCapacityMax.WithLabelValues(pid), e.ccsCapacityCurrent.WithLabelValues(pid))
+	return nil
+}
+
+// collectOldCapacity collects the old generation columns shared by
+// jstat -gccapacity and -gcoldcapacity into the given gauges.
+func (e *Exporter) collectOldCapacity(ch chan<- prometheus.Metric, sample map[string]string, ogcmn, ogcmx, ogc, oc prometheus.Gauge) {
+	e.collectColumn(ch, ogcmn, sample, "OGCMN") // OGCMN: Minimum old generation capacity (kB).
+	e.collectColumn(ch, ogcmx, sample, "OGCMX") // OGCMX: Maximum old generation capacity (kB).
+	e.collectColumn(ch, ogc, sample, "OGC")     // OGC: Current old generation capacity (kB).
+	e.collectColumn(ch, oc, sample, "OC")       // OC: Current old space capacity (kB).
+}
+
+// collectMetaCapacity collects the metaspace and compressed class space
+// columns shared by jstat -gccapacity and -gcmetacapacity into the given
+// gauges. The CCS columns are absent on JVMs without compressed class pointers.
+func (e *Exporter) collectMetaCapacity(ch chan<- prometheus.Metric, sample map[string]string, mcmn, mcmx, mc, ccsmn, ccsmx, ccsc prometheus.Gauge) {
+	e.collectColumn(ch, mcmn, sample, "MCMN")   // MCMN: Minimum metaspace capacity (kB).
+	e.collectColumn(ch, mcmx, sample, "MCMX")   // MCMX: Maximum metaspace capacity (kB).
+	e.collectColumn(ch, mc, sample, "MC")       // MC: Metaspace capacity (kB).
+	e.collectColumn(ch, ccsmn, sample, "CCSMN") // CCSMN: Compressed class space minimum capacity (kB).
+	e.collectColumn(ch, ccsmx, sample, "CCSMX") // CCSMX: Compressed class space maximum capacity (kB).
+	e.collectColumn(ch, ccsc, sample, "CCSC")   // CCSC: Compressed class space capacity (kB).
+}
+
+// collectColumn sets the metric to the named column of a parsed jstat sample
+// and collects it. Columns are located through the header so that layouts from
+// other JDK versions still read the right field. Columns missing from the
+// header are skipped, and so are the ones JDK 11 and later print as "-" where
+// they do not apply, such as CGC under Parallel GC. A column missing from a
+// truncated line is counted as an error.
+func (e *Exporter) collectColumn(ch chan<- prometheus.Metric, m valueMetric, sample map[string]string, name string) {
+	field, ok := sample[name]
+	if !ok || field == "-" {
+		return
+	}
+	if field == "" {
+		slog.Warn("No value for column in jstat output", "column", name)
+		e.scrapeErrors.Inc()
+		return
+	}
+	e.collectValue(ch, m, name, field, e.scale(name))
+}
+
+// collectSum sets the metric to the sum of the named columns, which must share
+// a unit, and collects it. Nothing is collected unless every column is present
+// and parses; those errors are already reported when the columns themselves
+// are collected.
+func (e *Exporter) collectSum(ch chan<- prometheus.Metric, m valueMetric, sample map[string]string, names ...string) {
+	sum, ok := sumColumns(sample, names...)
+	if !ok {
+		return
+	}
+	m.Set(sum * e.scale(names[0]))
+	m.Collect(ch)
+}
+
+// collectRatio sets the metric to the sum of the used columns divided by the
+// sum of the capacity columns and collects it. Nothing is collected when a
+// column is unavailable or the capacity is 0.
+func (e *Exporter) collectRatio(ch chan<- prometheus.Metric, m valueMetric, sample map[string]string, used []string, capacity []string) {
+	u, ok := sumColumns(sample, used...)
+	if !ok {
+		return
+	}
+	c, ok := sumColumns(sample, capacity...)
+	if !ok || c <= 0 {
+		return
+	}
+	m.Set(u / c)
+	m.Collect(ch)
+}
+
+// sumColumns adds up the named columns of a sample in jstat's own units.
+// Columns printed as "-" are left out, so that e.g. the heap of ZGC, which
+// has no young generation, is the old space alone. It reports false if a
+// column is missing or does not parse, or if every column is "-".
+func sumColumns(sample map[string]string, names ...string) (float64, bool) {
+	sum := 0.0
+	found := false
+	for _, name := range names {
+		if sample[name] == "-" {
+			continue
+		}
+		value, err := parseValue(sample[name])
+		if err != nil {
+			return 0, false
+		}
+		sum += value
+		found = true
+	}
+	return sum, found
+}
+
+// scale returns the factor a column is multiplied by when it is exported.
+func (e *Exporter) scale(name string) float64 {
+	if e.bytes && kBColumns[name] {
+		return 1024
+	}
+	return 1
+}
+
+// collectValue sets the metric to the field of the named column times scale
+// and collects it. A field that does not parse is logged and counted, and the
+// metric is left out of this scrape rather than reporting a bogus value.
+func (e *Exporter) collectValue(ch chan<- prometheus.Metric, m valueMetric, name string, field string, scale float64) {
+	value, err := parseValue(field)
+	if err != nil {
+		slog.Warn("Failed to parse jstat output", "column", name, "err", err)
+		e.parseErrors.Inc()
+		e.scrapeErrors.Inc()
+		return
+	}
+	m.Set(value * scale)
+	m.Collect(ch)
+}
+
+// modeInterval returns the minimum time between jstat runs of mode: its
+// -interval.<mode> when set, and the target's interval otherwise.
+func (e *Exporter) modeInterval(mode string) time.Duration {
+	if interval := modeIntervals[mode]; interval != nil && *interval > 0 {
+		return *interval
+	}
+	return e.interval
+}
+
+// jstat runs jstat with the given option against pid and returns the header
+// line and the first sample line of its output. Within the option's
+// modeInterval the last sample is returned without running jstat.
+func (e *Exporter) jstat(option string, pid string) (string, string, error) {
+	if interval := e.modeInterval(strings.TrimPrefix(option, "-")); interval > 0 {
+		e.mu.Lock()
+		result, ok := e.lastScrape[jstatRun{pid, option}]
+		e.mu.Unlock()
+		if ok && time.Since(result.time) < interval {
+			return result.header, result.line, nil
+		}
+	}
+	args := e.jstatArgs(option)
+	var stderr bytes.Buffer
+	vmid := pid
+	if e.remote != "" {
+		vmid += "@" + e.remote
+	}
+	ctx, cancel := context.WithTimeout(e.ctx, e.jstatTimeout)
+	defer cancel()
+	cmd := toolCommand(ctx, e.jstatPath, append(args, vmid)...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if strings.Contains(string(out), errUnavailable.Error()) || strings.Contains(stderr.String(), errUnavailable.Error()) {
+		slog.Debug("jstat could not read the performance data", "option", option, "pid", pid, "err", errUnavailable)
+		return "", "", errUnavailable
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		slog.Warn("jstat wrote to stderr", "option", option, "pid", pid, "stderr", msg)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		err = fmt.Errorf("timed out after %s", e.jstatTimeout)
+	}
+	if err != nil {
+		e.commandErrors.WithLabelValues(strings.TrimPrefix(option, "-")).Inc()
+		return "", "", fmt.Errorf("jstat %s %s: %s", option, pid, err)
+	}
+	lines := strings.Split(string(out), "\n")
+	if len(lines) < 2 || strings.TrimSpace(lines[1]) == "" {
+		e.commandErrors.WithLabelValues(strings.TrimPrefix(option, "-")).Inc()
+		return "", "", fmt.Errorf("jstat %s %s: no sample in output %q", option, pid, out)
+	}
+
+	e.mu.Lock()
+	e.lastScrape[jstatRun{pid, option}] = jstatResult{time.Now(), lines[0], lines[1]}
+	e.mu.Unlock()
+	if e.timestamp {
+		e.setUptime(pid, lines[0], lines[1])
+	}
+	return lines[0], lines[1], nil
+}
+
+// jstatArgs returns the arguments jstat is run with for option, before the
+// vmid.
+func (e *Exporter) jstatArgs(option string) []string {
+	args := []string{option}
+	if e.timestamp {
+		args = append(args, "-t")
+	}
+	return args
+}
+
+// setUptime records the Timestamp column that jstat -t prepends to its output.
+// Every command reports it, so it is collected once per pid by Collect.
+func (e *Exporter) setUptime(pid string, header string, line string) {
+	field := parseSample(header, line)["Timestamp"]
+	if field == "" {
+		return
+	}
+	value, err := parseValue(field)
+	if err != nil {
+		slog.Warn("Failed to parse jstat output", "column", "Timestamp", "err", err)
+		e.parseErrors.Inc()
+		return
+	}
+	e.jvmUptime.WithLabelValues(pid).Set(value)
+}
+
+// parseValue parses a numeric jstat field. Should a locale's decimal comma get
+// through anyway, as in "0,052", it is read as a decimal point. jstat does not
+// group digits, so a field with more than one separator, such as "1.234,5",
+// is an error rather than a guess.
+func parseValue(field string) (float64, error) {
+	if strings.Count(field, ",") == 1 && !strings.Contains(field, ".") {
+		field = strings.Replace(field, ",", ".", 1)
+	}
+	return strconv.ParseFloat(field, 64)
+}
+
+// parseSample maps each column name of a jstat header line to its field in a
+// sample line. A name that appears again gets its occurrence appended, e.g.
+// "Bytes2". The last column takes the rest of the line, since -compiler prints
+// FailedMethod as "class method". Columns without a field, as in a truncated
+// line, map to "".
+func parseSample(header string, line string) map[string]string {
+	names := strings.Fields(header)
+	parts := strings.Fields(line)
+	sample := make(map[string]string)
+	seen := make(map[string]int)
+	for i, name := range names {
+		seen[name]++
+		if seen[name] > 1 {
+			name += strconv.Itoa(seen[name])
+		}
+		switch {
+		case i >= len(parts):
+			sample[name] = ""
+		case i == len(names)-1:
+			sample[name] = strings.Join(parts[i:], " ")
+		default:
+			sample[name] = parts[i]
+		}
+	}
+	return sample
+}
+
+// setupLogging makes the default logger write -log.format to stderr and drop
+// messages below -log.level. logfmt is slog's key=value text output.
+func setupLogging(level string, format string) error {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{Level: l}
+	switch format {
+	case "logfmt":
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, opts)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, opts)))
+	default:
+		return fmt.Errorf("unknown log format %q", format)
+	}
+	return nil
+}
+
+// fatal logs msg as an error and exits, for errors the exporter cannot start
+// or keep serving with.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// optionsFlag is a repeatable flag of jstat options.
+type optionsFlag []string
+
+// String implements flag.Value.
+func (f *optionsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+// Set implements flag.Value. The leading dash of the option is optional, and
+// a repeated option is only run once.
+func (f *optionsFlag) Set(option string) error {
+	option = strings.TrimPrefix(option, "-")
+	if option == "" || invalidNameChars.MatchString(option) {
+		return fmt.Errorf("jstat option %q is not a single word", option)
+	}
+	if knownMode(option) {
+		return fmt.Errorf("jstat option %s is already collected, -collect.extra-columns exports its other columns", option)
+	}
+	for _, o := range *f {
+		if o == option {
+			return nil
 		}
 	}
+	*f = append(*f, option)
+	return nil
+}
+
+// labelsFlag is a repeatable name=value flag.
+type labelsFlag map[string]string
+
+// String implements flag.Value.
+func (f labelsFlag) String() string {
+	pairs := make([]string, 0, len(f))
+	for name, value := range f {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set implements flag.Value.
+func (f labelsFlag) Set(pair string) error {
+	i := strings.Index(pair, "=")
+	if i < 0 {
+		return fmt.Errorf("label %q is not name=value", pair)
+	}
+	name := pair[:i]
+	if !model.LegacyValidation.IsValidLabelName(name) || strings.HasPrefix(name, "__") {
+		return fmt.Errorf("invalid label name %q", name)
+	}
+	switch name {
+	case "pid", "target", "command", "cause", "method", "args", "jstat_path":
+		return fmt.Errorf("label %s is set by the exporter", name)
+	}
+	f[name] = pair[i+1:]
+	return nil
+}
+
+// splitList splits a comma-separated flag value into its entries with the
+// spaces around them trimmed, leaving out empty and repeated ones.
+func splitList(value string) []string {
+	var entries []string
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || seen[entry] {
+			continue
+		}
+		seen[entry] = true
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// parsePids returns the pids of a -target.pid value, trimmed and without
+// repeats, or an error for one that is not a pid.
+func parsePids(value string) ([]string, error) {
+	pids := splitList(value)
+	for _, pid := range pids {
+		if n, err := strconv.Atoi(pid); err != nil || n <= 0 {
+			return nil, fmt.Errorf("%q is not a pid", pid)
+		}
+	}
+	return pids, nil
+}
+
+// isFlagSet reports whether the named flag was given on the command line.
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// basicAuth wraps h so that it requires HTTP basic authentication with the
+// given credentials. An empty user leaves h unprotected.
+func basicAuth(h http.Handler, user string, password string) http.Handler {
+	if user == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		userOK := subtle.ConstantTimeCompare([]byte(u), []byte(user)) == 1
+		passwordOK := subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1
+		if !ok || !userOK || !passwordOK {
+			w.Header().Set("WWW-Authenticate", `Basic realm="jstat_exporter"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// clientAuthConfig returns a TLS config that requires client certificates
+// signed by one of the CAs in the given PEM file.
+func clientAuthConfig(caFile string) (*tls.Config, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.RequireAndVerifyClientCert,
+	}, nil
 }
 
 func main() {
 	flag.Parse()
 
-	exporter := NewExporter(*jstatPath, *targetPid)
-	prometheus.MustRegister(exporter)
+	if *showVersion {
+		fmt.Println(version.Print("jstat_exporter"))
+		os.Exit(0)
+	}
+	if err := setupLogging(*logLevel, *logFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	// Serving plain HTTP because half of the TLS flags are set would expose
+	// the metrics the user meant to protect.
+	if (*tlsCert == "") != (*tlsKey == "") {
+		fatal("-web.tls-cert and -web.tls-key must be set together")
+	}
+	if *tlsClientCA != "" && *tlsCert == "" {
+		fatal("-web.tls-client-ca needs -web.tls-cert and -web.tls-key")
+	}
+	if *authUser == "" && (*authPassword != "" || *authPassFile != "") {
+		fatal("-web.auth-password and -web.auth-password-file need -web.auth-user")
+	}
+	if *authUser != "" && *authPassword == "" && *authPassFile == "" {
+		fatal("-web.auth-user needs -web.auth-password or -web.auth-password-file")
+	}
 
-	log.Printf("Starting Server: %s", *listenAddress)
-	http.Handle(*metricsPath, prometheus.Handler())
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	var jstatErr error
+	if !isFlagSet("jstat.path") {
+		*jstatPath, jstatErr = lookupTool("jstat", *jstatPath)
+	}
+	if *heapInfoFlag && !isFlagSet("jcmd.path") {
+		var err error
+		*jcmdPath, err = lookupTool("jcmd", *jcmdPath)
+		if err != nil {
+			slog.Warn("-jcmd.heap-info will not work", "err", err)
+		}
+	}
+	lister, ok := jvmListers[*discovery]
+	if !ok {
+		fatal("Unknown -discovery.command", "discovery.command", *discovery)
+	}
+	// Only targets given by name need the tool, so not finding it is an
+	// error for them alone.
+	var discoveryErr error
+	if !isFlagSet(*discovery + ".path") {
+		*lister.path, discoveryErr = lookupTool(*discovery, *lister.path)
+	}
+	discoveryPath := *lister.path
+
+	// An unset -target leaves a single target without a name, which uses
+	// -target.pid or -target.pidfile.
+	names := []string{""}
+	if *target != "" {
+		// A repeated name would register the same exporter twice.
+		names = splitList(*target)
+		if len(names) == 0 {
+			fatal("No target name in -target", "target", *target)
+		}
+	}
+	pids, err := parsePids(*targetPid)
+	if err != nil {
+		fatal("Invalid -target.pid", "err", err)
+	}
+	var targets []Target
+	for _, name := range names {
+		targets = append(targets, Target{
+			Name:       name,
+			MatchArgs:  *matchArgs,
+			AllMatches: *allMatches,
+			User:       *targetUser,
+			Remote:     *targetRemote,
+			Pids:       pids,
+			PidFile:    *targetPidFile,
+		})
+	}
+	if *configFile != "" {
+		config, err := LoadConfig(*configFile)
+		if err != nil {
+			fatal("Failed to load config file", "err", err)
+		}
+		targets = config.Targets
+	}
+	// Targets only need telling apart when there is more than one of them.
+	labelTargets := *configFile != "" || len(targets) > 1
+
+	registerer := prometheus.DefaultRegisterer
+	gatherer := prometheus.DefaultGatherer
+	if *noDefaultMetrics {
+		registry := prometheus.NewRegistry()
+		registerer = registry
+		gatherer = registry
+	}
+
+	var exporters []*Exporter
+	for i := range targets {
+		t := &targets[i]
+		// -jstat.skip-validation is for tools that only show up after
+		// startup, such as a JDK mounted later, so a tool that is not found
+		// is run from its default path.
+		if t.JstatPath == "" {
+			if jstatErr != nil {
+				if !*skipValidation {
+					fatal("Failed to find jstat", "err", jstatErr)
+				}
+				slog.Warn("Failed to find jstat, using its default path", "target", t.Name, "path", *jstatPath, "err", jstatErr)
+			}
+			t.JstatPath = *jstatPath
+		}
+		if t.Name != "" && discoveryErr != nil {
+			if !*skipValidation {
+				fatal("Failed to find -discovery.command", "discovery.command", *discovery, "err", discoveryErr)
+			}
+			slog.Warn("Failed to find -discovery.command, using its default path", "target", t.Name, "discovery.command", *discovery, "path", discoveryPath, "err", discoveryErr)
+		}
+		if !*skipValidation {
+			if err := checkExecutable(t.JstatPath); err != nil {
+				fatal("Invalid jstat", "err", err)
+			}
+			if t.Name != "" {
+				if err := checkExecutable(discoveryPath); err != nil {
+					fatal("Invalid -discovery.command", "discovery.command", *discovery, "err", err)
+				}
+			}
+		}
+		t.Timestamp = *jstatTime
+		constLabels := prometheus.Labels{}
+		for name, value := range metricLabels {
+			constLabels[name] = value
+		}
+		if labelTargets {
+			constLabels["target"] = t.Name
+		}
+		exporter := NewExporter(*t, discoveryPath, constLabels)
+		registerer.MustRegister(exporter)
+		exporters = append(exporters, exporter)
+	}
+
+	if *oneshotMode {
+		healthy, err := oneshot(os.Stdout, exporters)
+		if err != nil {
+			fatal("Failed to collect", "err", err)
+		}
+		if !healthy {
+			os.Exit(1)
+		}
+		return
+	}
+
+	prometheus.WrapRegistererWith(prometheus.Labels(metricLabels), registerer).MustRegister(versioncollector.NewCollector("jstat_exporter"))
+
+	slog.Info("Starting jstat_exporter", "version", version.Info())
+	slog.Info("Starting server", "address", *listenAddress)
+	if *authPassFile != "" {
+		password, err := os.ReadFile(*authPassFile)
+		if err != nil {
+			fatal("Failed to read -web.auth-password-file", "err", err)
+		}
+		*authPassword = strings.TrimRight(string(password), "\r\n")
+		if *authPassword == "" {
+			fatal("-web.auth-password-file is empty", "file", *authPassFile)
+		}
+	}
+	auth := func(h http.Handler) http.Handler {
+		return basicAuth(h, *authUser, *authPassword)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(*metricsPath, auth(promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{EnableOpenMetrics: true})))
+	mux.Handle("/probe", auth(probeHandler(targets, Target{MatchArgs: *matchArgs, AllMatches: *allMatches, User: *targetUser, JstatPath: *jstatPath, Remote: *targetRemote, Timestamp: *jstatTime}, discoveryPath, prometheus.Labels(metricLabels))))
+	mux.Handle("/", auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
 		<head><title>jstat Exporter</title></head>
 		<body>
 		<h1>jstat Exporter</h1>
 		<p><a href="` + *metricsPath + `">Metrics</a></p>
+		<p><a href="/debug/jstat">Last jstat output</a></p>
+		<p>Single JVMs can be probed with <code>/probe?target=&lt;main class or jar&gt;</code> or <code>/probe?pid=&lt;pid&gt;</code>.</p>
 		</body>
 		</html>`))
+	})))
+	mux.Handle("/debug/jstat", auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for i, exporter := range exporters {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			exporter.WriteDebug(w)
+		}
+	})))
+	if *enablePprof {
+		mux.Handle("/debug/pprof/", auth(http.HandlerFunc(pprof.Index)))
+		mux.Handle("/debug/pprof/cmdline", auth(http.HandlerFunc(pprof.Cmdline)))
+		mux.Handle("/debug/pprof/profile", auth(http.HandlerFunc(pprof.Profile)))
+		mux.Handle("/debug/pprof/symbol", auth(http.HandlerFunc(pprof.Symbol)))
+		mux.Handle("/debug/pprof/trace", auth(http.HandlerFunc(pprof.Trace)))
+	}
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		for _, exporter := range exporters {
+			if exporter.Healthy() {
+				continue
+			}
+			http.Error(w, "jstat target unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
 	})
-	err := http.ListenAndServe(*listenAddress, nil)
-	if err != nil {
-		log.Fatal(err)
+	pushCtx, stopPush := context.WithCancel(context.Background())
+	if *pushGateway != "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "unknown"
+		}
+		grouping := map[string]string{"instance": hostname}
+		for name, value := range pushLabels {
+			grouping[name] = value
+		}
+		go pushMetrics(pushCtx, *pushGateway, *pushJob, grouping, *pushInterval, gatherer)
 	}
 
+	server := &http.Server{
+		Addr:         *listenAddress,
+		Handler:      mux,
+		ReadTimeout:  *readTimeout,
+		WriteTimeout: *writeTimeout,
+		IdleTimeout:  *idleTimeout,
+	}
+	// done is closed once the shutdown has killed every jstat and jps still
+	// running, so main does not return and orphan them.
+	done := make(chan struct{})
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		sig := <-sigs
+		slog.Info("Shutting down", "signal", sig)
+		stopPush()
+		ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Error("Failed to shut down server", "err", err)
+		}
+		cancel()
+		for _, exporter := range exporters {
+			exporter.Stop()
+		}
+		close(done)
+	}()
+	// A unix socket is removed again when Shutdown closes the listener.
+	network, address := "tcp", *listenAddress
+	if strings.HasPrefix(address, "unix:") {
+		network, address = "unix", strings.TrimPrefix(address, "unix:")
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		fatal("Failed to listen", "address", *listenAddress, "err", err)
+	}
+	if *tlsCert != "" {
+		if *tlsClientCA != "" {
+			server.TLSConfig, err = clientAuthConfig(*tlsClientCA)
+			if err != nil {
+				fatal("Invalid -web.tls-client-ca", "err", err)
+			}
+		}
+		err = server.ServeTLS(listener, *tlsCert, *tlsKey)
+	} else {
+		err = server.Serve(listener)
+	}
+	if err != nil && err != http.ErrServerClosed {
+		fatal("Failed to serve", "err", err)
+	}
+	<-done
 }
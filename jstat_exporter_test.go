@@ -0,0 +1,1365 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Output of jstat from JDK 7, 8, 11 and 17. JDK 7 has the PC and PU columns of
+// the permanent generation instead of metaspace. JDK 11 and later add the CGC
+// and CGCT columns before GCT, and print "-" for them under collectors without
+// concurrent cycles, such as Parallel.
+const (
+	jdk7GcHeader = " S0C    S1C    S0U    S1U      EC       EU        OC         OU       PC     PU    YGC     YGCT    FGC    FGCT     GCT   "
+	jdk7GcLine   = "8704.0 8704.0  0.0   2536.1 69952.0  22879.6   174784.0    8660.7   21248.0 11587.7      4    0.047   0      0.000    0.047"
+
+	jdk7GcoldHeader = "   PC       PU        OC          OU       YGC    FGC    FGCT     GCT   "
+	jdk7GcoldLine   = " 21248.0  11587.7    174784.0      8660.7      4     0    0.000    0.047"
+
+	jdk7GccapacityHeader = " NGCMN    NGCMX     NGC     S0C   S1C       EC      OGCMN      OGCMX       OGC         OC      PGCMN    PGCMX     PGC       PC     YGC    FGC "
+	jdk7GccapacityLine   = " 87360.0 1397760.0  87360.0 8704.0 8704.0  69952.0   174784.0  2796224.0   174784.0   174784.0  21248.0  83968.0  21248.0  21248.0      4     0"
+
+	jdk8GcHeader = " S0C    S1C    S0U    S1U      EC       EU        OC         OU       MC     MU    CCSC   CCSU   YGC     YGCT    FGC    FGCT     GCT   "
+	jdk8GcLine   = "10752.0 10752.0  0.0   3264.6  65536.0   5243.5   175104.0    1024.0   4480.0 774.6  384.0   76.6       7    0.052   1      0.031    0.083"
+
+	jdk11GcHeader = " S0C    S1C    S0U    S1U      EC       EU        OC         OU       MC     MU    CCSC   CCSU   YGC     YGCT    FGC    FGCT    CGC    CGCT     GCT   "
+	jdk11GcLine   = " 0.0   3072.0  0.0   3072.0  22528.0   4096.0   236544.0    12865.5  21248.0 20636.7 2560.0 2278.3      4    0.020   0      0.000   2      0.003    0.023"
+
+	jdk17GcHeader = "    S0C         S1C         S0U         S1U          EC           EU           OC           OU          MC         MU       CCSC      CCSU     YGC     YGCT     FGC    FGCT     CGC    CGCT       GCT   "
+	jdk17GcLine   = "        0.0      4096.0         0.0      4096.0      28672.0       8192.0     229376.0      14336.0    21376.0    20796.8    2688.0    2430.9      3     0.012     0     0.000     2     0.003     0.015"
+
+	jdk17ParallelGcLine = "    10752.0     10752.0         0.0      3264.6      65536.0       5243.5     175104.0       1024.0     4480.0      774.6     384.0      76.6      7     0.052     1     0.031     -         -     0.083"
+
+	jdk17ZgcGcLine = "          -           -           -           -            -            -     540672.0      36864.0    11264.0    10866.0    1152.0    1009.4      0     0.000     0     0.000     4     0.012     0.012"
+
+	jdk8GcutilHeader = "  S0     S1     E      O      M     CCS    YGC     YGCT    FGC    FGCT     GCT   "
+	jdk8GcutilLine   = "  0.00  30.36   8.00   0.58  17.29  19.94      7    0.052     1    0.031    0.083"
+
+	jdk17GcutilHeader = "  S0     S1     E      O      M     CCS    YGC     YGCT     FGC    FGCT     CGC    CGCT       GCT   "
+	jdk17GcutilLine   = "     -  100.00  28.57   6.25  97.29  90.44      3     0.012     0     0.000     2     0.003     0.015"
+
+	jdk8GcnewHeader = " S0C    S1C    S0U    S1U   TT MTT  DSS      EC       EU     YGC     YGCT  "
+	jdk8GcnewLine   = "10752.0 10752.0    0.0 3264.6 15  15 5376.0  65536.0   5243.5      7    0.052"
+
+	jdk8GcoldHeader = "   MC       MU      CCSC     CCSU       OC          OU       YGC    FGC    FGCT     GCT   "
+	jdk8GcoldLine   = "  4480.0    774.6    384.0     76.6    175104.0      1024.0      7     1    0.031    0.083"
+
+	jdk8GccapacityHeader = " NGCMN    NGCMX     NGC     S0C   S1C       EC      OGCMN      OGCMX       OGC         OC       MCMN     MCMX      MC     CCSMN    CCSMX     CCSC    YGC    FGC "
+	jdk8GccapacityLine   = " 87040.0 1397760.0 109056.0 10752.0 10752.0  87552.0   175104.0  2796544.0   262144.0   262144.0      0.0 1056768.0   4480.0      0.0 1048576.0    384.0      7     1"
+
+	// G1 has no fixed new and old generation sizes, so it reports the whole
+	// maximum heap as both NGCMX and OGCMX.
+	jdk17GccapacityHeader = "    NGCMN        NGCMX         NGC          S0C       S1C          EC         OGCMN        OGCMX         OGC          OC         MCMN       MCMX        MC       CCSMN      CCSMX       CCSC     YGC     FGC    CGC "
+	jdk17GccapacityLine   = "        0.0     262144.0      32768.0        0.0    4096.0     28672.0          0.0     262144.0     229376.0     229376.0        0.0   1069056.0    21376.0        0.0  1048576.0     2688.0      3      0      2"
+)
+
+func TestParseSample(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		line   string
+		want   map[string]string
+	}{
+		{
+			name:   "JDK 8 -gc",
+			header: jdk8GcHeader,
+			line:   jdk8GcLine,
+			want: map[string]string{
+				"S0C": "10752.0", "S1U": "3264.6", "EU": "5243.5", "OU": "1024.0",
+				"YGC": "7", "YGCT": "0.052", "FGC": "1", "FGCT": "0.031", "GCT": "0.083",
+			},
+		},
+		{
+			name:   "JDK 7 -gc with permanent generation columns",
+			header: jdk7GcHeader,
+			line:   jdk7GcLine,
+			want: map[string]string{
+				"S0C": "8704.0", "EU": "22879.6", "OU": "8660.7", "PC": "21248.0", "PU": "11587.7",
+				"YGC": "4", "YGCT": "0.047", "FGC": "0", "GCT": "0.047",
+			},
+		},
+		{
+			name:   "JDK 11 -gc with concurrent GC columns",
+			header: jdk11GcHeader,
+			line:   jdk11GcLine,
+			want: map[string]string{
+				"S0C": "0.0", "S1U": "3072.0", "MU": "20636.7", "CCSU": "2278.3",
+				"YGC": "4", "YGCT": "0.020", "FGC": "0", "CGC": "2", "CGCT": "0.003", "GCT": "0.023",
+			},
+		},
+		{
+			name:   "JDK 17 -gc with concurrent GC columns",
+			header: jdk17GcHeader,
+			line:   jdk17GcLine,
+			want: map[string]string{
+				"S0C": "0.0", "S1U": "4096.0", "CCSU": "2430.9",
+				"YGC": "3", "FGC": "0", "FGCT": "0.000", "CGC": "2", "CGCT": "0.003", "GCT": "0.015",
+			},
+		},
+		{
+			name:   "JDK 17 -gc under Parallel GC",
+			header: jdk17GcHeader,
+			line:   jdk17ParallelGcLine,
+			want:   map[string]string{"FGC": "1", "FGCT": "0.031", "CGC": "-", "CGCT": "-", "GCT": "0.083"},
+		},
+		{
+			name:   "JDK 8 -gcutil",
+			header: jdk8GcutilHeader,
+			line:   jdk8GcutilLine,
+			want:   map[string]string{"S0": "0.00", "S1": "30.36", "CCS": "19.94", "FGC": "1", "GCT": "0.083"},
+		},
+		{
+			name:   "JDK 17 -gcutil with a placeholder",
+			header: jdk17GcutilHeader,
+			line:   jdk17GcutilLine,
+			want:   map[string]string{"S0": "-", "S1": "100.00", "CGC": "2", "CGCT": "0.003", "GCT": "0.015"},
+		},
+		{
+			name:   "-t Timestamp column",
+			header: "Timestamp        S0     S1     E      O      M     CCS    YGC     YGCT    FGC    FGCT     GCT   ",
+			line:   "          125.3   0.00  30.36   8.00   0.58  17.29  19.94      7    0.052     1    0.031    0.083",
+			want:   map[string]string{"Timestamp": "125.3", "S0": "0.00", "GCT": "0.083"},
+		},
+		{
+			name:   "decimal commas",
+			header: "  S0     S1     E      O      M     CCS    YGC     YGCT    FGC    FGCT     GCT   ",
+			line:   "  0,00  30,36   8,00   0,58  17,29  19,94      7    0,052     1    0,031    0,083",
+			want:   map[string]string{"S1": "30,36", "YGCT": "0,052", "GCT": "0,083"},
+		},
+		{
+			name:   "truncated line",
+			header: jdk8GcHeader,
+			line:   "10752.0 10752.0  0.0   3264.6  65536.0",
+			want:   map[string]string{"S0C": "10752.0", "EC": "65536.0", "EU": "", "FGC": "", "GCT": ""},
+		},
+		{
+			name:   "garbage line",
+			header: "  S0     S1     E",
+			line:   "Could not attach to 1234",
+			want:   map[string]string{"S0": "Could", "S1": "not", "E": "attach to 1234"},
+		},
+		{
+			name:   "repeated column",
+			header: "Loaded  Bytes  Unloaded  Bytes     Time   ",
+			line:   "  3120  6240.5       12    18.2       1.52",
+			want:   map[string]string{"Loaded": "3120", "Bytes": "6240.5", "Unloaded": "12", "Bytes2": "18.2", "Time": "1.52"},
+		},
+		{
+			name:   "last column with spaces",
+			header: "Compiled Failed Invalid   Time   FailedType FailedMethod",
+			line:   "    1913      1       0     4.53          1 java/lang/String indexOf",
+			want:   map[string]string{"Failed": "1", "FailedType": "1", "FailedMethod": "java/lang/String indexOf"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sample := parseSample(tt.header, tt.line)
+			for column, want := range tt.want {
+				got, ok := sample[column]
+				if !ok {
+					t.Errorf("column %s missing", column)
+					continue
+				}
+				if got != want {
+					t.Errorf("column %s = %q, want %q", column, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSampleColumns(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"JDK 7 -gc", jdk7GcHeader, 15},
+		{"JDK 8 -gc", jdk8GcHeader, 17},
+		{"JDK 11 -gc", jdk11GcHeader, 19},
+		{"JDK 17 -gc", jdk17GcHeader, 19},
+		{"JDK 8 -gcutil", jdk8GcutilHeader, 11},
+		{"JDK 17 -gcutil", jdk17GcutilHeader, 13},
+		{"empty header", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := len(parseSample(tt.header, "")); got != tt.want {
+				t.Errorf("got %d columns, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		field   string
+		want    float64
+		wantErr bool
+	}{
+		{field: "0.000", want: 0},
+		{field: "12865.5", want: 12865.5},
+		{field: "7", want: 7},
+		{field: "1397760.0", want: 1397760},
+		{field: "0,052", want: 0.052},
+		{field: "17,29", want: 17.29},
+		{field: "1.234,5", wantErr: true},
+		{field: "1,234.5", wantErr: true},
+		{field: "1,234,5", wantErr: true},
+		{field: "1.234.567,5", wantErr: true},
+		{field: "-", wantErr: true},
+		{field: "", wantErr: true},
+		{field: "Could", wantErr: true},
+		{field: "attach to 1234", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			got, err := parseValue(tt.field)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseValue(%q) = %g, want an error", tt.field, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseValue(%q): %s", tt.field, err)
+			}
+			if got != tt.want {
+				t.Errorf("parseValue(%q) = %g, want %g", tt.field, got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeRun is the canned result of a jstat, jps or jcmd run.
+type fakeRun struct {
+	stdout string
+	stderr string
+	exit   int
+}
+
+// fakeTools makes execCommand start TestHelperProcess instead of the JDK
+// tools. Each run prints the result that runs holds for its arguments, such
+// as "-gc 1234"; runs without one fail.
+func fakeTools(t *testing.T, runs map[string]fakeRun) {
+	t.Setenv("GO_WANT_HELPER_PROCESS", "1")
+	execCommand = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		run, ok := runs[strings.Join(args, " ")]
+		if !ok {
+			run = fakeRun{stderr: fmt.Sprintf("no canned output for %s %s", name, strings.Join(args, " ")), exit: 1}
+		}
+		return exec.CommandContext(ctx, os.Args[0], "-test.run=^TestHelperProcess$", "--", run.stdout, run.stderr, strconv.Itoa(run.exit))
+	}
+	t.Cleanup(func() { execCommand = exec.CommandContext })
+}
+
+// jstatOutput returns what jstat prints for a single sample.
+func jstatOutput(header string, line string) fakeRun {
+	return fakeRun{stdout: header + "\n" + line + "\n"}
+}
+
+// TestHelperProcess is the JDK tool that fakeTools substitutes. It is not a
+// test of its own.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+	if len(args) != 4 {
+		os.Exit(2)
+	}
+	fmt.Fprint(os.Stdout, args[1])
+	fmt.Fprint(os.Stderr, args[2])
+	code, _ := strconv.Atoi(args[3])
+	os.Exit(code)
+}
+
+// gather collects e once and returns the value of each sample by metric name
+// and its labels other than pid, e.g. `jstat_command_errors_total{command="gc"}`.
+func gather(t *testing.T, e *Exporter) map[string]float64 {
+	t.Helper()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := make(map[string]float64)
+	for _, family := range families {
+		for _, m := range family.Metric {
+			var labels []string
+			for _, pair := range m.Label {
+				if pair.GetName() != "pid" {
+					labels = append(labels, fmt.Sprintf("%s=%q", pair.GetName(), pair.GetValue()))
+				}
+			}
+			name := family.GetName()
+			if len(labels) > 0 {
+				name += "{" + strings.Join(labels, ",") + "}"
+			}
+			switch {
+			case m.Gauge != nil:
+				samples[name] = m.Gauge.GetValue()
+			case m.Counter != nil:
+				samples[name] = m.Counter.GetValue()
+			case m.Untyped != nil:
+				samples[name] = m.Untyped.GetValue()
+			}
+		}
+	}
+	return samples
+}
+
+// checkSamples reports the samples that are missing or differ from want. A
+// negative want means the sample must not be there.
+func checkSamples(t *testing.T, samples map[string]float64, want map[string]float64) {
+	t.Helper()
+	for name, value := range want {
+		got, ok := samples[name]
+		switch {
+		case value < 0 && ok:
+			t.Errorf("%s = %g, want no sample", name, got)
+		case value < 0:
+		case !ok:
+			t.Errorf("%s missing", name)
+		case math.Abs(got-value) > 1e-9*math.Max(1, math.Abs(value)):
+			t.Errorf("%s = %g, want %g", name, got, value)
+		}
+	}
+}
+
+// testPid is a pid that is running for as long as the tests are, so that
+// Collect does not skip it as exited.
+var testPid = strconv.Itoa(os.Getpid())
+
+func TestCollect(t *testing.T) {
+	tests := []struct {
+		name string
+		runs map[string]fakeRun
+		want map[string]float64
+	}{
+		{
+			name: "JDK 8",
+			runs: map[string]fakeRun{
+				"-gc " + testPid:     jstatOutput(jdk8GcHeader, jdk8GcLine),
+				"-gcutil " + testPid: jstatOutput(jdk8GcutilHeader, jdk8GcutilLine),
+			},
+			want: map[string]float64{
+				"jstat_up":                                                       1,
+				"jstat_monitored_jvms":                                           1,
+				"jstat_survivor0_capacity_bytes":                                 10752 * 1024,
+				"jstat_eden_capacity_bytes":                                      65536 * 1024,
+				"jstat_heap_used_bytes":                                          (0 + 3264.6 + 5243.5 + 1024) * 1024,
+				"jstat_heap_committed_bytes":                                     (10752 + 10752 + 65536 + 175104) * 1024,
+				"jstat_young_gc_count_total":                                     7,
+				"jstat_full_gc_time_seconds_total":                               0.031,
+				"jstat_gc_time_seconds_total":                                    0.083,
+				"jstat_survivor1_used_percent":                                   30.36,
+				"jstat_old_used_percent":                                         0.58,
+				"jstat_concurrent_gc_count_total":                                -1,
+				"jstat_parse_errors_total":                                       0,
+				"jstat_exporter_scrape_errors_total":                             0,
+				`jstat_command_info{args="-gc",command="gc",jstat_path="jstat"}`: 1,
+			},
+		},
+		{
+			name: "JDK 17 G1",
+			runs: map[string]fakeRun{
+				"-gc " + testPid:     jstatOutput(jdk17GcHeader, jdk17GcLine),
+				"-gcutil " + testPid: jstatOutput(jdk17GcutilHeader, jdk17GcutilLine),
+			},
+			want: map[string]float64{
+				"jstat_up":                               1,
+				"jstat_full_gc_count_total":              0,
+				"jstat_full_gc_time_seconds_total":       0,
+				"jstat_concurrent_gc_count_total":        2,
+				"jstat_concurrent_gc_time_seconds_total": 0.003,
+				"jstat_gc_time_seconds_total":            0.015,
+				"jstat_survivor0_used_percent":           -1,
+				"jstat_survivor1_used_percent":           100,
+				"jstat_parse_errors_total":               0,
+				"jstat_exporter_scrape_errors_total":     0,
+			},
+		},
+		{
+			name: "JDK 17 Parallel",
+			runs: map[string]fakeRun{
+				"-gc " + testPid:     jstatOutput(jdk17GcHeader, jdk17ParallelGcLine),
+				"-gcutil " + testPid: jstatOutput(jdk8GcutilHeader, jdk8GcutilLine),
+			},
+			want: map[string]float64{
+				"jstat_full_gc_count_total":              1,
+				"jstat_full_gc_time_seconds_total":       0.031,
+				"jstat_gc_time_seconds_total":            0.083,
+				"jstat_concurrent_gc_count_total":        -1,
+				"jstat_concurrent_gc_time_seconds_total": -1,
+				"jstat_parse_errors_total":               0,
+				"jstat_exporter_scrape_errors_total":     0,
+			},
+		},
+		{
+			name: "JDK 17 ZGC",
+			runs: map[string]fakeRun{
+				"-gc " + testPid:     jstatOutput(jdk17GcHeader, jdk17ZgcGcLine),
+				"-gcutil " + testPid: jstatOutput(jdk8GcutilHeader, jdk8GcutilLine),
+			},
+			want: map[string]float64{
+				"jstat_survivor0_capacity_bytes":     -1,
+				"jstat_eden_capacity_bytes":          -1,
+				"jstat_old_capacity_bytes":           540672 * 1024,
+				"jstat_heap_used_bytes":              36864 * 1024,
+				"jstat_heap_committed_bytes":         540672 * 1024,
+				"jstat_concurrent_gc_count_total":    4,
+				"jstat_parse_errors_total":           0,
+				"jstat_exporter_scrape_errors_total": 0,
+			},
+		},
+		{
+			name: "jstat fails",
+			runs: map[string]fakeRun{
+				"-gc " + testPid:     {stderr: testPid + " not found", exit: 1},
+				"-gcutil " + testPid: jstatOutput(jdk8GcutilHeader, jdk8GcutilLine),
+			},
+			want: map[string]float64{
+				"jstat_up":                                 1,
+				"jstat_young_gc_count_total":               -1,
+				"jstat_survivor1_used_percent":             30.36,
+				`jstat_command_errors_total{command="gc"}`: 1,
+				"jstat_exporter_scrape_errors_total":       1,
+			},
+		},
+		{
+			name: "no mode returns data",
+			runs: map[string]fakeRun{
+				"-gc " + testPid:     {stdout: "\n"},
+				"-gcutil " + testPid: {stderr: testPid + " not found", exit: 1},
+			},
+			want: map[string]float64{
+				"jstat_up":             0,
+				"jstat_monitored_jvms": 0,
+				`jstat_command_errors_total{command="gc"}`:     1,
+				`jstat_command_errors_total{command="gcutil"}`: 1,
+				"jstat_exporter_scrape_errors_total":           2,
+			},
+		},
+		{
+			name: "performance data unavailable",
+			runs: map[string]fakeRun{
+				"-gc " + testPid:     {stdout: testPid + " process information unavailable\n"},
+				"-gcutil " + testPid: jstatOutput(jdk8GcutilHeader, jdk8GcutilLine),
+			},
+			want: map[string]float64{
+				"jstat_up":                           1,
+				"jstat_survivor1_used_percent":       30.36,
+				"jstat_exporter_scrape_errors_total": 0,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeTools(t, tt.runs)
+			e := NewExporter(Target{JstatPath: "jstat", Pids: []string{testPid}, Modes: []string{"gc", "gcutil"}}, "jps", nil)
+			defer e.Stop()
+			checkSamples(t, gather(t, e), tt.want)
+		})
+	}
+}
+
+func TestHealthy(t *testing.T) {
+	exited := exec.Command(os.Args[0], "-test.run=^$")
+	if err := exited.Run(); err != nil {
+		t.Fatal(err)
+	}
+	exitedPid := strconv.Itoa(exited.Process.Pid)
+	gc := jstatOutput(jdk17GcHeader, jdk17GcLine)
+	tests := []struct {
+		name   string
+		pid    string
+		before fakeRun
+		after  fakeRun
+		gather bool
+		want   bool
+	}{
+		{name: "collects before the first scrape", pid: testPid, after: gc, want: true},
+		{name: "jstat fails before the first scrape", pid: testPid, after: fakeRun{exit: 1}, want: false},
+		{name: "last scrape was up", pid: testPid, before: gc, after: fakeRun{exit: 1}, gather: true, want: true},
+		{name: "last scrape was down", pid: testPid, before: fakeRun{exit: 1}, after: gc, gather: true, want: false},
+		{name: "pid exited", pid: exitedPid, after: gc, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewExporter(Target{JstatPath: "jstat", Pids: []string{tt.pid}, Modes: []string{"gc"}}, "jps", nil)
+			defer e.Stop()
+			if tt.gather {
+				fakeTools(t, map[string]fakeRun{"-gc " + tt.pid: tt.before})
+				gather(t, e)
+			}
+			fakeTools(t, map[string]fakeRun{"-gc " + tt.pid: tt.after})
+			if got := e.Healthy(); got != tt.want {
+				t.Errorf("Healthy() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHealthyAfterPidExited(t *testing.T) {
+	// Until it is waited for, the child counts as running, so the scrape
+	// is up, and Healthy then has to notice that it exited since.
+	child := exec.Command(os.Args[0], "-test.run=^$")
+	if err := child.Start(); err != nil {
+		t.Fatal(err)
+	}
+	pid := strconv.Itoa(child.Process.Pid)
+	fakeTools(t, map[string]fakeRun{"-gc " + pid: jstatOutput(jdk17GcHeader, jdk17GcLine)})
+	e := NewExporter(Target{JstatPath: "jstat", Pids: []string{pid}, Modes: []string{"gc"}}, "jps", nil)
+	defer e.Stop()
+	checkSamples(t, gather(t, e), map[string]float64{"jstat_up": 1})
+	child.Wait()
+	if e.Healthy() {
+		t.Error("Healthy() = true after the pid exited")
+	}
+}
+
+func TestTargetInterval(t *testing.T) {
+	tests := []struct {
+		name string
+		flag time.Duration
+		want map[string]float64
+	}{
+		{
+			name: "reuses the sample",
+			want: map[string]float64{
+				"jstat_young_gc_count_total":                  3,
+				`jstat_sample_interval_seconds{command="gc"}`: 3600,
+				`jstat_command_errors_total{command="gc"}`:    -1,
+			},
+		},
+		{
+			name: "-interval.gc takes precedence",
+			flag: time.Nanosecond,
+			want: map[string]float64{
+				"jstat_young_gc_count_total":                  -1,
+				`jstat_sample_interval_seconds{command="gc"}`: 1e-9,
+				`jstat_command_errors_total{command="gc"}`:    1,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*modeIntervals["gc"] = tt.flag
+			defer func() { *modeIntervals["gc"] = 0 }()
+			e := NewExporter(Target{JstatPath: "jstat", Pids: []string{testPid}, Modes: []string{"gc"}, Interval: time.Hour}, "jps", nil)
+			defer e.Stop()
+			fakeTools(t, map[string]fakeRun{"-gc " + testPid: jstatOutput(jdk17GcHeader, jdk17GcLine)})
+			gather(t, e)
+			fakeTools(t, map[string]fakeRun{"-gc " + testPid: {exit: 1}})
+			checkSamples(t, gather(t, e), tt.want)
+		})
+	}
+}
+
+func TestLoadConfigInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte("targets:\n  - name: app.jar\n    interval: 30s\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := config.Targets[0].Interval; got != 30*time.Second {
+		t.Errorf("interval = %s, want 30s", got)
+	}
+}
+
+// gatherMode collects a single jstat mode from a jstat run that prints run.
+func gatherMode(t *testing.T, mode string, run fakeRun) map[string]float64 {
+	t.Helper()
+	fakeTools(t, map[string]fakeRun{"-" + mode + " " + testPid: run})
+	e := NewExporter(Target{JstatPath: "jstat", Pids: []string{testPid}, Modes: []string{mode}}, "jps", nil)
+	defer e.Stop()
+	return gather(t, e)
+}
+
+func TestYoungGC(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		line   string
+		want   map[string]float64
+	}{
+		{
+			name:   "JDK 8",
+			header: jdk8GcHeader,
+			line:   jdk8GcLine,
+			want:   map[string]float64{"jstat_young_gc_count_total": 7, "jstat_young_gc_time_seconds_total": 0.052},
+		},
+		{
+			name:   "JDK 17",
+			header: jdk17GcHeader,
+			line:   jdk17GcLine,
+			want:   map[string]float64{"jstat_young_gc_count_total": 3, "jstat_young_gc_time_seconds_total": 0.012},
+		},
+		{
+			name:   "line cut short after YGC",
+			header: jdk8GcHeader,
+			line:   "10752.0 10752.0  0.0   3264.6  65536.0   5243.5   175104.0    1024.0   4480.0 774.6  384.0   76.6       7",
+			want:   map[string]float64{"jstat_young_gc_count_total": 7, "jstat_young_gc_time_seconds_total": -1, "jstat_up": 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkSamples(t, gatherMode(t, "gc", jstatOutput(tt.header, tt.line)), tt.want)
+		})
+	}
+}
+
+func TestGCTotalTime(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		line   string
+		want   float64
+	}{
+		{"JDK 8", jdk8GcHeader, jdk8GcLine, 0.083},
+		{"JDK 11 with CGC and CGCT before GCT", jdk11GcHeader, jdk11GcLine, 0.023},
+		{"JDK 17", jdk17GcHeader, jdk17GcLine, 0.015},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkSamples(t, gatherMode(t, "gc", jstatOutput(tt.header, tt.line)), map[string]float64{"jstat_gc_time_seconds_total": tt.want})
+		})
+	}
+}
+
+func TestGCCountExemplars(t *testing.T) {
+	fakeTools(t, map[string]fakeRun{"-gc " + testPid: jstatOutput(jdk17GcHeader, jdk17GcLine)})
+	e := NewExporter(Target{JstatPath: "jstat", Pids: []string{testPid}, Modes: []string{"gc"}}, "jps", nil)
+	defer e.Stop()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(e)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exemplars := map[string]bool{
+		"jstat_young_gc_count_total":      true,
+		"jstat_full_gc_count_total":       true,
+		"jstat_concurrent_gc_count_total": true,
+		"jstat_gc_time_seconds_total":     false,
+	}
+	for _, family := range families {
+		want, ok := exemplars[family.GetName()]
+		if !ok {
+			continue
+		}
+		delete(exemplars, family.GetName())
+		c := family.Metric[0].GetCounter()
+		exemplar := c.GetExemplar()
+		switch {
+		case !want && exemplar != nil:
+			t.Errorf("%s has exemplar %v, want none", family.GetName(), exemplar)
+		case !want:
+		case exemplar == nil:
+			t.Errorf("%s has no exemplar", family.GetName())
+		case exemplar.GetValue() != c.GetValue() || exemplar.GetTimestamp() == nil:
+			t.Errorf("%s exemplar = %v, want value %g and a timestamp", family.GetName(), exemplar, c.GetValue())
+		}
+	}
+	for name := range exemplars {
+		t.Errorf("%s missing", name)
+	}
+}
+
+func TestGcCapacities(t *testing.T) {
+	tests := []struct {
+		name   string
+		legacy bool
+		want   map[string]float64
+	}{
+		{
+			name: "bytes",
+			want: map[string]float64{
+				"jstat_survivor0_capacity_bytes": 10752 * 1024,
+				"jstat_survivor1_capacity_bytes": 10752 * 1024,
+				"jstat_eden_capacity_bytes":      65536 * 1024,
+				"jstat_old_capacity_bytes":       175104 * 1024,
+			},
+		},
+		{
+			name:   "legacy names in kB",
+			legacy: true,
+			want: map[string]float64{
+				"jstat_sv0Capacity":  10752,
+				"jstat_sv1Capacity":  10752,
+				"jstat_edenCapacity": 65536,
+				"jstat_oldCapacity":  175104,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*legacyNames = tt.legacy
+			defer func() { *legacyNames = false }()
+			checkSamples(t, gatherMode(t, "gc", jstatOutput(jdk8GcHeader, jdk8GcLine)), tt.want)
+		})
+	}
+}
+
+func TestSizeHelp(t *testing.T) {
+	tests := []struct {
+		name   string
+		legacy bool
+		bytes  bool
+		want   string
+	}{
+		{"bytes", false, false, "Current survivor space 0 capacity in bytes (S0C of jstat -gc)."},
+		{"legacy names in kB", true, false, "Current survivor space 0 capacity in kB (S0C of jstat -gc)."},
+		{"legacy names in bytes", true, true, "Current survivor space 0 capacity in bytes (S0C of jstat -gc)."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*legacyNames, *metricBytes = tt.legacy, tt.bytes
+			defer func() { *legacyNames, *metricBytes = false, false }()
+			e := NewExporter(Target{JstatPath: "jstat", Pids: []string{testPid}}, "jps", nil)
+			defer e.Stop()
+			ch := make(chan *prometheus.Desc, 1)
+			e.sv0Capacity.Describe(ch)
+			if desc := (<-ch).String(); !strings.Contains(desc, fmt.Sprintf("help: %q", tt.want)) {
+				t.Errorf("desc = %s, want help %q", desc, tt.want)
+			}
+		})
+	}
+}
+
+func TestGccapacity(t *testing.T) {
+	samples := gatherMode(t, "gccapacity", jstatOutput(jdk8GccapacityHeader, jdk8GccapacityLine))
+	checkSamples(t, samples, map[string]float64{
+		"jstat_new_capacity_min_bytes":        87040 * 1024,
+		"jstat_new_capacity_max_bytes":        1397760 * 1024,
+		"jstat_new_capacity_bytes":            109056 * 1024,
+		"jstat_old_gen_capacity_min_bytes":    175104 * 1024,
+		"jstat_old_gen_capacity_max_bytes":    2796544 * 1024,
+		"jstat_old_gen_capacity_bytes":        262144 * 1024,
+		"jstat_gccapacity_old_capacity_bytes": 262144 * 1024,
+		"jstat_metaspace_capacity_max_bytes":  1056768 * 1024,
+		"jstat_metaspace_capacity_bytes":      4480 * 1024,
+		"jstat_gccapacity_ccs_capacity_bytes": 384 * 1024,
+		"jstat_exporter_scrape_errors_total":  0,
+	})
+}
+
+func TestPermGen(t *testing.T) {
+	fakeTools(t, map[string]fakeRun{
+		"-gc " + testPid:         jstatOutput(jdk7GcHeader, jdk7GcLine),
+		"-gcold " + testPid:      jstatOutput(jdk7GcoldHeader, jdk7GcoldLine),
+		"-gccapacity " + testPid: jstatOutput(jdk7GccapacityHeader, jdk7GccapacityLine),
+	})
+	e := NewExporter(Target{JstatPath: "jstat", Pids: []string{testPid}, Modes: []string{"gc", "gcold", "gccapacity"}}, "jps", nil)
+	defer e.Stop()
+	checkSamples(t, gather(t, e), map[string]float64{
+		"jstat_permgen_capacity_bytes":            21248 * 1024,
+		"jstat_permgen_used_bytes":                11587.7 * 1024,
+		"jstat_permgen_capacity_min_bytes":        21248 * 1024,
+		"jstat_permgen_capacity_max_bytes":        83968 * 1024,
+		"jstat_permgen_generation_capacity_bytes": 21248 * 1024,
+		"jstat_heap_used_bytes":                   (0 + 2536.1 + 22879.6 + 8660.7) * 1024,
+		"jstat_heap_utilization_ratio":            (0 + 2536.1 + 22879.6 + 8660.7) / (1397760 + 2796224),
+		"jstat_old_used_bytes":                    8660.7 * 1024,
+		"jstat_exporter_scrape_errors_total":      0,
+		"jstat_metaspace_used_bytes":              -1,
+		"jstat_metaspace_capacity_bytes":          -1,
+		"jstat_metaspace_capacity_min_bytes":      -1,
+		"jstat_metaspace_capacity_max_bytes":      -1,
+		"jstat_metaspace_utilization_ratio":       -1,
+		"jstat_ccs_capacity_bytes":                -1,
+		"jstat_gccapacity_ccs_capacity_bytes":     -1,
+	})
+}
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"app.jar", []string{"app.jar"}},
+		{"app.jar, Main ,", []string{"app.jar", "Main"}},
+		{"app.jar,app.jar", []string{"app.jar"}},
+		{"app.jar, Main, app.jar", []string{"app.jar", "Main"}},
+		{" , ", nil},
+	}
+	for _, tt := range tests {
+		if got := splitList(tt.value); strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("splitList(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestHeapUtilization(t *testing.T) {
+	tests := []struct {
+		name       string
+		gc         fakeRun
+		gccapacity fakeRun
+		want       float64
+	}{
+		{
+			name:       "Parallel adds the new and old maximums",
+			gc:         jstatOutput(jdk8GcHeader, jdk8GcLine),
+			gccapacity: jstatOutput(jdk8GccapacityHeader, jdk8GccapacityLine),
+			want:       (0 + 3264.6 + 5243.5 + 1024) / (1397760 + 2796544),
+		},
+		{
+			name:       "G1 reports the maximum heap as both",
+			gc:         jstatOutput(jdk17GcHeader, jdk17GcLine),
+			gccapacity: jstatOutput(jdk17GccapacityHeader, jdk17GccapacityLine),
+			want:       (0 + 4096 + 8192 + 14336) / 262144.0,
+		},
+		{
+			name:       "no maximum",
+			gc:         jstatOutput(jdk8GcHeader, jdk8GcLine),
+			gccapacity: jstatOutput(jdk8GccapacityHeader, strings.NewReplacer("1397760.0", "      0.0", "2796544.0", "      0.0").Replace(jdk8GccapacityLine)),
+			want:       -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeTools(t, map[string]fakeRun{"-gc " + testPid: tt.gc, "-gccapacity " + testPid: tt.gccapacity})
+			e := NewExporter(Target{JstatPath: "jstat", Pids: []string{testPid}, Modes: []string{"gc", "gccapacity"}}, "jps", nil)
+			defer e.Stop()
+			checkSamples(t, gather(t, e), map[string]float64{"jstat_heap_utilization_ratio": tt.want})
+		})
+	}
+}
+
+func TestOptionsFlag(t *testing.T) {
+	var options optionsFlag
+	for _, option := range []string{"printcompilation", "-printcompilation", "printcompilation"} {
+		if err := options.Set(option); err != nil {
+			t.Fatalf("Set(%q): %s", option, err)
+		}
+	}
+	if options.String() != "printcompilation" {
+		t.Errorf("options = %q, want printcompilation once", options.String())
+	}
+	for _, option := range []string{"gc", "", "print compilation"} {
+		if err := options.Set(option); err == nil {
+			t.Errorf("Set(%q) returned no error", option)
+		}
+	}
+}
+
+func TestJstatOption(t *testing.T) {
+	jstatOptions = optionsFlag{"printcompilation"}
+	defer func() { jstatOptions = nil }()
+	fakeTools(t, map[string]fakeRun{
+		"-printcompilation " + testPid: jstatOutput("Compiled  Size  Type Method", "     207     64    1 java/lang/CharacterDataLatin1 toUpperCase"),
+	})
+	e := NewExporter(Target{JstatPath: "jstat", Pids: []string{testPid}, Modes: []string{"class"}}, "jps", nil)
+	defer e.Stop()
+	checkSamples(t, gather(t, e), map[string]float64{
+		"jstat_up":                        1,
+		"jstat_printcompilation_compiled": 207,
+		"jstat_printcompilation_size":     64,
+		"jstat_printcompilation_type":     1,
+		"jstat_printcompilation_method":   -1,
+	})
+}
+
+func TestParsePids(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+		err   bool
+	}{
+		{value: "", want: nil},
+		{value: "123, 456", want: []string{"123", "456"}},
+		{value: "123,123", want: []string{"123"}},
+		{value: "123,,456,", want: []string{"123", "456"}},
+		{value: "123,abc", err: true},
+		{value: ":0", err: true},
+		{value: "-1", err: true},
+	}
+	for _, tt := range tests {
+		got, err := parsePids(tt.value)
+		if (err != nil) != tt.err {
+			t.Errorf("parsePids(%q) error = %v, want error %t", tt.value, err, tt.err)
+			continue
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("parsePids(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+const (
+	jdk8GccauseHeader  = "  S0     S1     E      O      M     CCS    YGC     YGCT    FGC    FGCT     GCT    LGCC                 GCC                 "
+	jdk17GccauseHeader = "  S0     S1     E      O      M     CCS    YGC     YGCT     FGC    FGCT     CGC    CGCT       GCT       LGCC                 GCC                 "
+)
+
+func TestSplitGcCauses(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		line    string
+		last    string
+		current string
+		err     bool
+	}{
+		{
+			name:    "JDK 8",
+			header:  jdk8GccauseHeader,
+			line:    "  0.00  99.45  41.62  10.71  97.05  93.69      7    0.052     0    0.000    0.052  Allocation Failure         No GC        ",
+			last:    "Allocation Failure",
+			current: "No GC",
+		},
+		{
+			name:    "JDK 17",
+			header:  jdk17GccauseHeader,
+			line:    "  0.00 100.00  28.57   1.16  97.41  88.31      3     0.012     0     0.000     2     0.001     0.013 G1 Evacuation Pause         No GC        ",
+			last:    "G1 Evacuation Pause",
+			current: "No GC",
+		},
+		{
+			name:    "cause wider than its column",
+			header:  jdk17GccauseHeader,
+			line:    "  0.00 100.00  28.57   1.16  97.41  88.31      3     0.012     0     0.000     2     0.001     0.013 Metadata GC Threshold        No GC        ",
+			last:    "Metadata GC Threshold",
+			current: "No GC",
+		},
+		{
+			name:    "collection running",
+			header:  jdk17GccauseHeader,
+			line:    "  0.00 100.00  28.57   1.16  97.41  88.31      3     0.012     0     0.000     2     0.001     0.013 G1 Humongous Allocation  G1 Evacuation Pause ",
+			last:    "G1 Humongous Allocation",
+			current: "G1 Evacuation Pause",
+		},
+		{
+			name:    "numeric column wider than its header",
+			header:  jdk8GccauseHeader,
+			line:    "  0.00  99.45  41.62  10.71  97.05  93.69 123456 12345.678     0    0.000 12345.678  Allocation Failure         No GC        ",
+			last:    "Allocation Failure",
+			current: "No GC",
+		},
+		{
+			name:    "no collection yet",
+			header:  jdk8GccauseHeader,
+			line:    "  0.00   0.00  20.00   0.00  17.20  19.85      0    0.000     0    0.000    0.000        No GC                No GC        ",
+			last:    "No GC",
+			current: "No GC",
+		},
+		{
+			name:   "causes one space apart",
+			header: jdk8GccauseHeader,
+			line:   "  0.00  99.45  41.62  10.71  97.05  93.69      7    0.052     0    0.000    0.052 G1 Humongous Allocation G1 Evacuation Pause",
+			err:    true,
+		},
+		{
+			name:   "line cut short",
+			header: jdk8GccauseHeader,
+			line:   "  0.00  99.45  41.62  10.71  97.05  93.69      7    0.052",
+			err:    true,
+		},
+		{
+			name:   "not -gccause",
+			header: jdk8GcutilHeader,
+			line:   jdk8GcutilLine,
+			err:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			last, current, err := splitGcCauses(tt.header, tt.line)
+			if (err != nil) != tt.err {
+				t.Fatalf("splitGcCauses() error = %v, want error %t", err, tt.err)
+			}
+			if last != tt.last || current != tt.current {
+				t.Errorf("splitGcCauses() = %q, %q, want %q, %q", last, current, tt.last, tt.current)
+			}
+		})
+	}
+}
+
+func TestGccause(t *testing.T) {
+	line := "  0.00 100.00  28.57   1.16  97.41  88.31      3     0.012     0     0.000     2     0.001     0.013 Metadata GC Threshold        No GC        "
+	checkSamples(t, gatherMode(t, "gccause", jstatOutput(jdk17GccauseHeader, line)), map[string]float64{
+		`jstat_last_gc_cause{cause="Metadata GC Threshold"}`: 1,
+		`jstat_current_gc_cause{cause="No GC"}`:              1,
+		"jstat_up":                                           1,
+	})
+}
+
+func TestJps(t *testing.T) {
+	fakeTools(t, map[string]fakeRun{
+		"-l": {stdout: `12345 org.apache.catalina.startup.Bootstrap
+23456 /opt/app/app.jar
+34567 com.example.Bootstrap
+45678 sun.tools.jps.Jps
+56789 -- process information unavailable
+`},
+		"-lm": {stdout: `12345 org.apache.catalina.startup.Bootstrap start
+23456 /opt/app/app.jar --spring.profiles.active=prod
+45678 sun.tools.jps.Jps -lm
+`},
+		"": {stdout: `12345 org.apache.catalina.startup.Bootstrap start
+23456 /opt/app/app.jar --spring.profiles.active=prod
+67890 jdk.jcmd/sun.tools.jcmd.JCmd
+`},
+	})
+	tests := []struct {
+		name      string
+		tool      string
+		target    string
+		matchArgs bool
+		want      []string
+	}{
+		{name: "fully qualified class", tool: "jps", target: "org.apache.catalina.startup.Bootstrap", want: []string{"12345"}},
+		{name: "short class matches both", tool: "jps", target: "Bootstrap", want: []string{"12345", "34567"}},
+		{name: "package suffix", tool: "jps", target: "startup.Bootstrap", want: []string{"12345"}},
+		{name: "jar name", tool: "jps", target: "app.jar", want: []string{"23456"}},
+		{name: "jar path", tool: "jps", target: "/opt/app/app.jar", want: []string{"23456"}},
+		{name: "partial class name", tool: "jps", target: "strap"},
+		{name: "unavailable JVM", tool: "jps", target: "unavailable"},
+		{name: "arguments", tool: "jps", target: "--spring.profiles.active=prod", matchArgs: true, want: []string{"23456"}},
+		{name: "arguments need match-args", tool: "jps", target: "start"},
+		{name: "jcmd", tool: "jcmd", target: "Bootstrap", want: []string{"12345"}},
+		{name: "jcmd arguments", tool: "jcmd", target: "profiles.active=prod", matchArgs: true, want: []string{"23456"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pids, err := Jps(context.Background(), tt.tool, tt.tool, "", tt.target, tt.matchArgs)
+			if tt.want == nil {
+				if err == nil {
+					t.Errorf("got pids %v, want an error", pids)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(pids, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got pids %v, want %v", pids, tt.want)
+			}
+		})
+	}
+}
+
+func TestGarbageOutput(t *testing.T) {
+	tests := []struct {
+		name string
+		mode string
+		run  fakeRun
+		want map[string]float64
+	}{
+		{
+			name: "message instead of a sample",
+			mode: "gc",
+			run:  jstatOutput(jdk8GcHeader, "Could not attach to the target JVM"),
+			want: map[string]float64{
+				// S0C, S1C, EC and OC get a word each, and the
+				// columns after OC get no field at all.
+				"jstat_parse_errors_total":           4,
+				"jstat_exporter_scrape_errors_total": 11,
+				"jstat_survivor0_capacity_bytes":     -1,
+				"jstat_heap_used_bytes":              -1,
+				"jstat_gc_time_seconds_total":        -1,
+			},
+		},
+		{
+			name: "repeated header",
+			mode: "gcutil",
+			run:  jstatOutput(jdk8GcutilHeader, jdk8GcutilHeader),
+			want: map[string]float64{
+				"jstat_parse_errors_total":           6,
+				"jstat_exporter_scrape_errors_total": 6,
+				"jstat_survivor0_used_percent":       -1,
+			},
+		},
+		{
+			name: "grouped digits",
+			mode: "class",
+			run:  jstatOutput("Loaded  Bytes  Unloaded  Bytes     Time   ", "  3120  6.240,5       12    18,2       1,52"),
+			want: map[string]float64{
+				"jstat_parse_errors_total":           1,
+				"jstat_exporter_scrape_errors_total": 1,
+				"jstat_classes_loaded_total":         3120,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkSamples(t, gatherMode(t, tt.mode, tt.run), tt.want)
+		})
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		noAuth   bool
+		want     int
+	}{
+		{name: "right credentials", user: "prometheus", password: "secret", want: http.StatusOK},
+		{name: "wrong password", user: "prometheus", password: "wrong", want: http.StatusUnauthorized},
+		{name: "wrong user", user: "admin", password: "secret", want: http.StatusUnauthorized},
+		{name: "no credentials", noAuth: true, want: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if !tt.noAuth {
+				r.SetBasicAuth(tt.user, tt.password)
+			}
+			w := httptest.NewRecorder()
+			basicAuth(ok, "prometheus", "secret").ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 response has no WWW-Authenticate header")
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	basicAuth(ok, "", "").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("status without authentication = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestNewUtilization(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want map[string]float64
+	}{
+		{
+			name: "JDK 8",
+			line: jdk8GcnewLine,
+			want: map[string]float64{
+				"jstat_eden_utilization_ratio":     5243.5 / 65536,
+				"jstat_survivor_utilization_ratio": (0 + 3264.6) / (10752 + 10752),
+			},
+		},
+		{
+			name: "G1 with one survivor space",
+			line: "    0.0 4096.0    0.0 4096.0 15  15 2048.0  28672.0   8192.0      3    0.012",
+			want: map[string]float64{
+				"jstat_eden_utilization_ratio":     8192.0 / 28672,
+				"jstat_survivor_utilization_ratio": 1,
+			},
+		},
+		{
+			name: "zero capacity",
+			line: "    0.0    0.0    0.0    0.0 15  15    0.0      0.0      0.0      0    0.000",
+			want: map[string]float64{
+				"jstat_eden_utilization_ratio":     -1,
+				"jstat_survivor_utilization_ratio": -1,
+				"jstat_eden_used_bytes":            0,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkSamples(t, gatherMode(t, "gcnew", jstatOutput(jdk8GcnewHeader, tt.line)), tt.want)
+		})
+	}
+}
+
+func TestOldUtilization(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want map[string]float64
+	}{
+		{
+			name: "JDK 8",
+			line: jdk8GcoldLine,
+			want: map[string]float64{"jstat_old_utilization_ratio": 1024.0 / 175104, "jstat_old_used_bytes": 1024 * 1024},
+		},
+		{
+			name: "zero capacity",
+			line: "  4480.0    774.6    384.0     76.6         0.0         0.0      7     1    0.031    0.083",
+			want: map[string]float64{"jstat_old_utilization_ratio": -1, "jstat_old_used_bytes": 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkSamples(t, gatherMode(t, "gcold", jstatOutput(jdk8GcoldHeader, tt.line)), tt.want)
+		})
+	}
+}
+
+func TestMetaUtilization(t *testing.T) {
+	tests := []struct {
+		name       string
+		gccapacity string
+		want       float64
+	}{
+		{name: "JDK 8", gccapacity: jdk8GccapacityLine, want: 774.6 / 1056768},
+		{name: "zero maximum", gccapacity: strings.Replace(jdk8GccapacityLine, "1056768.0", "      0.0", 1), want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeTools(t, map[string]fakeRun{
+				"-gcold " + testPid:      jstatOutput(jdk8GcoldHeader, jdk8GcoldLine),
+				"-gccapacity " + testPid: jstatOutput(jdk8GccapacityHeader, tt.gccapacity),
+			})
+			e := NewExporter(Target{JstatPath: "jstat", Pids: []string{testPid}, Modes: []string{"gcold", "gccapacity"}}, "jps", nil)
+			defer e.Stop()
+			checkSamples(t, gather(t, e), map[string]float64{"jstat_metaspace_utilization_ratio": tt.want})
+		})
+	}
+
+	// Without -gcold there is no metaspace use to divide.
+	checkSamples(t, gatherMode(t, "gccapacity", jstatOutput(jdk8GccapacityHeader, jdk8GccapacityLine)), map[string]float64{"jstat_metaspace_utilization_ratio": -1})
+}
+
+func TestGCOverhead(t *testing.T) {
+	runs := map[string]fakeRun{"-gc " + testPid: jstatOutput(jdk8GcHeader, jdk8GcLine)}
+	fakeTools(t, runs)
+	e := NewExporter(Target{JstatPath: "jstat", Pids: []string{testPid}, Modes: []string{"gc"}}, "jps", nil)
+	defer e.Stop()
+
+	// The first sample has nothing to compare with.
+	checkSamples(t, gather(t, e), map[string]float64{"jstat_gc_overhead_ratio": -1})
+
+	// Half a second of GC since a sample moved ten seconds back.
+	e.mu.Lock()
+	first := e.gcTimes[testPid]
+	first.time = first.time.Add(-10 * time.Second)
+	e.gcTimes[testPid] = first
+	e.mu.Unlock()
+	runs["-gc "+testPid] = jstatOutput(jdk8GcHeader, strings.Replace(jdk8GcLine, "0.031    0.083", "0.031    0.583", 1))
+	samples := gather(t, e)
+	e.mu.Lock()
+	want := 0.5 / e.lastScrape[jstatRun{testPid, "-gc"}].time.Sub(first.time).Seconds()
+	e.mu.Unlock()
+	checkSamples(t, samples, map[string]float64{"jstat_gc_overhead_ratio": want})
+
+	// A cached sample keeps the overhead of the scrape that took it.
+	*modeIntervals["gc"] = time.Hour
+	defer func() { *modeIntervals["gc"] = 0 }()
+	runs["-gc "+testPid] = fakeRun{exit: 1}
+	checkSamples(t, gather(t, e), map[string]float64{"jstat_gc_overhead_ratio": want})
+
+	// GCT going back means the pid was reused, so there is no overhead.
+	*modeIntervals["gc"] = 0
+	e.mu.Lock()
+	last := e.gcTimes[testPid]
+	last.time = last.time.Add(-10 * time.Second)
+	e.gcTimes[testPid] = last
+	e.mu.Unlock()
+	runs["-gc "+testPid] = jstatOutput(jdk8GcHeader, jdk8GcLine)
+	checkSamples(t, gather(t, e), map[string]float64{"jstat_gc_overhead_ratio": -1})
+}
+
+func TestClass(t *testing.T) {
+	const (
+		header = "Loaded  Bytes  Unloaded  Bytes     Time   "
+		line   = "  3120  6240.5       12    18.2       1.52"
+	)
+	tests := []struct {
+		name   string
+		legacy bool
+		want   map[string]float64
+	}{
+		{
+			name: "counters in bytes",
+			want: map[string]float64{
+				"jstat_classes_loaded_total":          3120,
+				"jstat_class_loaded_bytes_total":      6240.5 * 1024,
+				"jstat_classes_unloaded_total":        12,
+				"jstat_class_unloaded_bytes_total":    18.2 * 1024,
+				"jstat_class_load_time_seconds_total": 1.52,
+				"jstat_class_loaded_bytes":            -1,
+				"jstat_class_load_time_seconds":       -1,
+				"jstat_exporter_scrape_errors_total":  0,
+			},
+		},
+		{
+			name:   "legacy names in kB",
+			legacy: true,
+			want: map[string]float64{
+				"jstat_classesLoaded":      3120,
+				"jstat_classBytesLoaded":   6240.5,
+				"jstat_classesUnloaded":    12,
+				"jstat_classBytesUnloaded": 18.2,
+				"jstat_classLoadSec":       1.52,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*legacyNames = tt.legacy
+			defer func() { *legacyNames = false }()
+			checkSamples(t, gatherMode(t, "class", jstatOutput(header, line)), tt.want)
+		})
+	}
+}
+
+func TestCompiler(t *testing.T) {
+	const header = "Compiled Failed Invalid   Time   FailedType FailedMethod"
+	tests := []struct {
+		name   string
+		legacy bool
+		line   string
+		want   map[string]float64
+	}{
+		{
+			name: "failed compilation",
+			line: "    1913      1       0     4.53          1 java/lang/String indexOf",
+			want: map[string]float64{
+				"jstat_compiler_tasks_total":                                           1913,
+				"jstat_compiler_failed_tasks_total":                                    1,
+				"jstat_compiler_invalidated_tasks_total":                               0,
+				"jstat_compiler_time_seconds_total":                                    4.53,
+				"jstat_compiler_time_seconds":                                          -1,
+				`jstat_compiler_last_failed_method{method="java/lang/String indexOf"}`: 1,
+			},
+		},
+		{
+			name: "no failed compilation",
+			line: "    1913      0       0     4.53          0             ",
+			want: map[string]float64{
+				"jstat_compiler_tasks_total":                   1913,
+				"jstat_compiler_time_seconds_total":            4.53,
+				`jstat_compiler_last_failed_method{method=""}`: -1,
+			},
+		},
+		{
+			name:   "legacy names",
+			legacy: true,
+			line:   "    1913      1       0     4.53          1 java/lang/String indexOf",
+			want: map[string]float64{
+				"jstat_compilerTasks": 1913,
+				"jstat_compilerSec":   4.53,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*legacyNames = tt.legacy
+			defer func() { *legacyNames = false }()
+			checkSamples(t, gatherMode(t, "compiler", jstatOutput(header, tt.line)), tt.want)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// oneshot collects every exporter once and writes the raw jstat output and
+// the resulting metrics to w. It reports whether all targets were healthy.
+func oneshot(w io.Writer, exporters []*Exporter) (bool, error) {
+	healthy := true
+	for i, exporter := range exporters {
+		registry := prometheus.NewRegistry()
+		if err := registry.Register(exporter); err != nil {
+			return false, err
+		}
+		families, err := registry.Gather()
+		if err != nil {
+			return false, err
+		}
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		exporter.WriteDebug(w)
+		fmt.Fprintln(w)
+		writeMetrics(w, families)
+		if !exporter.Healthy() {
+			healthy = false
+		}
+	}
+	return healthy, nil
+}
+
+// writeMetrics writes one aligned "name{labels} value" row per sample.
+func writeMetrics(w io.Writer, families []*dto.MetricFamily) {
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	for _, family := range families {
+		for _, m := range family.Metric {
+			labels := make([]string, 0, len(m.Label))
+			for _, pair := range m.Label {
+				labels = append(labels, fmt.Sprintf("%s=%q", pair.GetName(), pair.GetValue()))
+			}
+			sort.Strings(labels)
+			var value float64
+			switch {
+			case m.Gauge != nil:
+				value = m.Gauge.GetValue()
+			case m.Counter != nil:
+				value = m.Counter.GetValue()
+			case m.Untyped != nil:
+				value = m.Untyped.GetValue()
+			}
+			name := family.GetName()
+			if len(labels) > 0 {
+				name += "{" + strings.Join(labels, ",") + "}"
+			}
+			fmt.Fprintf(tw, "%s\t%g\n", name, value)
+		}
+	}
+	tw.Flush()
+}
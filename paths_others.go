@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+const (
+	defaultJstatPath = "/usr/bin/jstat"
+	defaultJpsPath   = "/usr/bin/jps"
+	defaultJcmdPath  = "/usr/bin/jcmd"
+)
@@ -0,0 +1,12 @@
+//go:build windows
+// +build windows
+
+package main
+
+// jstat, jps and jcmd are usually not installed in a fixed place on Windows,
+// so the defaults rely on %JAVA_HOME%\bin or %PATH%.
+const (
+	defaultJstatPath = "jstat.exe"
+	defaultJpsPath   = "jps.exe"
+	defaultJcmdPath  = "jcmd.exe"
+)
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// probeHandler serves the metrics of a single JVM given by the target or pid
+// query parameter, in the style of the blackbox exporter. A target that is
+// listed in the config file is probed with its configured settings; any other
+// target is resolved with jps and all modes are collected.
+func probeHandler(targets []Target, defaults Target, jpsPath string, constLabels prometheus.Labels) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t := defaults
+		name := r.URL.Query().Get("target")
+		pid := r.URL.Query().Get("pid")
+		switch {
+		case name != "" && pid != "":
+			http.Error(w, "only one of target and pid may be given", http.StatusBadRequest)
+			return
+		case name != "":
+			t.Name = name
+			for _, configured := range targets {
+				if configured.Name == name {
+					t = configured
+					break
+				}
+			}
+		case pid != "":
+			if _, err := strconv.Atoi(pid); err != nil {
+				http.Error(w, "pid must be a number", http.StatusBadRequest)
+				return
+			}
+			t.Pids = []string{pid}
+		default:
+			http.Error(w, "target or pid parameter is missing", http.StatusBadRequest)
+			return
+		}
+
+		exporter := NewExporter(t, jpsPath, constLabels)
+		defer exporter.Stop()
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(exporter)
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true}).ServeHTTP(w, r)
+	}
+}
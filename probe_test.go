@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestProbeDefaults(t *testing.T) {
+	otherPid := strconv.Itoa(os.Getppid())
+	fakeTools(t, map[string]fakeRun{
+		"-lm":             {stdout: testPid + " com.example.App --profile=test\n" + otherPid + " com.example.App --profile=test\n"},
+		"-l":              {stdout: testPid + " com.example.App\n" + otherPid + " com.example.App\n"},
+		"-gc " + testPid:  jstatOutput(jdk17GcHeader, jdk17GcLine),
+		"-gc " + otherPid: jstatOutput(jdk17GcHeader, jdk17GcLine),
+	})
+	tests := []struct {
+		name     string
+		defaults Target
+		target   string
+		want     string
+	}{
+		{name: "first match", target: "com.example.App", want: "jstat_monitored_jvms 1"},
+		{name: "all matches", defaults: Target{AllMatches: true}, target: "com.example.App", want: "jstat_monitored_jvms 2"},
+		{name: "arguments need match-args", target: "--profile=test", want: "jstat_monitored_jvms 0"},
+		{name: "match-args", defaults: Target{MatchArgs: true}, target: "--profile=test", want: "jstat_monitored_jvms 1"},
+		{name: "user", defaults: Target{AllMatches: true, User: "no-such-user"}, target: "com.example.App", want: "jstat_monitored_jvms 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defaults := tt.defaults
+			defaults.JstatPath = "jstat"
+			defaults.Modes = []string{"gc"}
+			w := httptest.NewRecorder()
+			probeHandler(nil, defaults, "jps", nil)(w, httptest.NewRequest(http.MethodGet, "/probe?target="+url.QueryEscape(tt.target), nil))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+			if body := w.Body.String(); !strings.Contains(body, tt.want+"\n") {
+				t.Errorf("probe output has no %q:\n%s", tt.want, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"os"
+	"strconv"
+)
+
+// processExited reports whether pid is a number that no local process has.
+// On Windows os.FindProcess opens the process and fails when it is gone.
+func processExited(pid string) bool {
+	n, err := strconv.Atoi(pid)
+	if err != nil {
+		return false
+	}
+	p, err := os.FindProcess(n)
+	if err != nil {
+		return true
+	}
+	p.Release()
+	return false
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// pushMetrics pushes the metrics of g to the Pushgateway at url every interval
+// until ctx is done, so JVMs that exit between scrapes are still recorded.
+// Every push replaces the metrics previously pushed for job and grouping.
+func pushMetrics(ctx context.Context, url string, job string, grouping map[string]string, interval time.Duration, g prometheus.Gatherer) {
+	pusher := push.New(url, job).Gatherer(g)
+	for name, value := range grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := pusher.Push(); err != nil {
+			slog.Error("Failed to push metrics", "gateway", url, "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}